@@ -0,0 +1,26 @@
+package cmdutil
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+)
+
+var (
+	DdnsAuthType = cfg.NewFlag[string]("auth-type", "ddns.auth.type",
+		"authentication between monitor and server, none, token or hmac").SetDefault(ddns.AuthNone).
+		SetValidate("oneof=none token hmac")
+	DdnsAuthSecret = cfg.NewFlag[string]("auth-secret", "ddns.auth.secret",
+		"shared token or hmac key, same for monitor and server").SetSecret(true)
+)
+
+// BindAuth 在 cmd 上绑定 ddns.auth 的配置
+func BindAuth(cmd *cobra.Command) {
+	DdnsAuthType.Bind(cmd)
+	DdnsAuthSecret.Bind(cmd)
+}
+
+// DdnsAuth 返回 ddns.auth 的认证配置，server 的 /my_ip 与管理接口均使用此认证
+func DdnsAuth() ddns.Auth {
+	return ddns.Auth{Type: DdnsAuthType.Get(), Secret: DdnsAuthSecret.Get()}
+}
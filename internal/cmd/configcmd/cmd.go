@@ -0,0 +1,111 @@
+package configcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/assets"
+	"github.com/vksir/vkiss-lib/internal/constant"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit config",
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show effective config with sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Printf("# %s\n", viper.ConfigFileUsed())
+			for _, s := range cfg.Settings(cmd) {
+				fmt.Printf("%s = %v  # %s\n", s.Key, s.Value, s.Source)
+			}
+			return nil
+		},
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print effective value of a key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, ok := cfg.Lookup(cmd, args[0])
+			if !ok {
+				return errutil.WrapNotFound(args[0])
+			}
+			fmt.Println(s.Value)
+			return nil
+		},
+	}
+
+	var forceString bool
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a key in config file, keeping comments",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := cfg.EncodeValue(args[1], forceString)
+			if err != nil {
+				return err
+			}
+			return cfg.SetInFile(viper.ConfigFileUsed(), args[0], value)
+		},
+	}
+	setCmd.Flags().BoolVar(&forceString, "string", false, "always write value as string")
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [command...]",
+		Short: "Validate config for a command, e.g. `config validate ddns monitor`",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, _, err := cmd.Root().Find(args)
+			if err != nil {
+				return errutil.Wrap(err)
+			}
+			err = cfg.Validate(target)
+			if err != nil {
+				return err
+			}
+			fmt.Println("config is valid")
+			return nil
+		},
+	}
+
+	var path string
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Write default config",
+		Args:  cobra.NoArgs,
+		// 不读取配置，避免在写入前生成默认配置
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fileutil.Exist(path) && !force {
+				return errutil.WrapF("config %s already exists, use --force to overwrite", path)
+			}
+			err := fileutil.Write(path, []byte(assets.DefaultConfig))
+			if err != nil {
+				return errutil.Wrap(err)
+			}
+			fmt.Println("write default config to", path)
+			return nil
+		},
+	}
+	initCmd.Flags().StringVar(&path, "path", constant.ConfPath, "path to write")
+	initCmd.Flags().BoolVar(&force, "force", false, "overwrite existing file")
+
+	cmd.AddCommand(showCmd)
+	cmd.AddCommand(getCmd)
+	cmd.AddCommand(setCmd)
+	cmd.AddCommand(validateCmd)
+	cmd.AddCommand(initCmd)
+	return cmd
+}
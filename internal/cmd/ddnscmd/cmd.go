@@ -1,43 +1,70 @@
 package ddnscmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/internal/cmd/cmdutil"
 	"github.com/vksir/vkiss-lib/internal/constant"
 	"github.com/vksir/vkiss-lib/internal/ddns"
 	"github.com/vksir/vkiss-lib/pkg/cfg"
 	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/log/logapi"
+	"github.com/vksir/vkiss-lib/pkg/middleware"
+	"github.com/vksir/vkiss-lib/pkg/registry"
 	"github.com/vksir/vkiss-lib/pkg/util/errutil"
 	"github.com/vksir/vkiss-lib/pkg/util/installutil"
+	"github.com/vksir/vkiss-lib/pkg/util/netutil"
 	"github.com/vksir/vkiss-lib/thirdpkg/systemctl"
-	"github.com/vksir/vkiss-lib/thirdpkg/tencentcloud"
 )
 
 var (
 	DdnsListen = cfg.NewFlag[string]("listen", "ddns.listen",
-		"listen address").SetDefault(":5801")
+		"listen address").SetDefault(":5801").SetValidate("required,hostname_port")
+	DdnsAdminListen = cfg.NewFlag[string]("admin-listen", "ddns.admin_listen",
+		"listen address of the admin api for log levels and records, disabled if empty").
+		SetDefault("127.0.0.1:5802").SetValidate("omitempty,hostname_port")
+	DdnsTrustedProxies = cfg.NewFlag[[]string]("trusted-proxies", "ddns.trusted_proxies",
+		"proxies whose X-Forwarded-For, X-Real-IP and PROXY protocol headers are trusted").
+		SetValidate("dive,cidr|ip")
+	DdnsProxyProtocol = cfg.NewFlag[bool]("proxy-protocol", "ddns.proxy_protocol",
+		"accept PROXY protocol v1/v2 headers from trusted proxies")
+	DdnsAllowCidrs = cfg.NewFlag[[]string]("allow-cidrs", "ddns.allow_cidrs",
+		"only serve clients from these cidrs or ips, all if empty").SetValidate("dive,cidr|ip")
+	DdnsRateLimit = cfg.NewFlag[float64]("rate-limit", "ddns.rate_limit.rate",
+		"requests per second allowed for each client, 0 for unlimited").SetValidate("min=0")
+	DdnsRateBurst = cfg.NewFlag[int]("rate-burst", "ddns.rate_limit.burst",
+		"burst of requests allowed for each client, rate if 0").SetValidate("min=0")
 	DdnsEndpoint = cfg.NewFlag[string]("endpoint", "ddns.endpoint",
-		"endpoint address")
-	DdnsInterval = cfg.NewFlag[int]("interval", "ddns.interval",
-		"monitor loop interval (minute)").SetDefault(20)
-
-	DdnsTcSecretId = cfg.NewFlag[string]("secret-id", "ddns.tencent_cloud.secret_id",
-		"tencent_cloud ddns secret id")
-	DdnsTcSecretKey = cfg.NewFlag[string]("secret-key", "ddns.tencent_cloud.secret_key",
-		"tencent_cloud ddns secret key")
-	DdnsTcDomain = cfg.NewFlag[string]("domain", "ddns.tencent_cloud.domain",
-		"tencent_cloud ddns domain")
-	DdnsTcSubDomain = cfg.NewFlag[string]("sub-domain", "ddns.tencent_cloud.sub_domain",
-		"tencent_cloud ddns sub domain")
-	DdnsTcRecordId = cfg.NewFlag[uint64]("record-id", "ddns.tencent_cloud.record_id",
-		"tencent_cloud ddns record id")
-	DdnsTcRecordLine = cfg.NewFlag[string]("record-line", "ddns.tencent_cloud.record_line",
-		"tencent_cloud ddns record line")
-	DdnsTcValue = cfg.NewFlag[string]("value", "ddns.tencent_cloud.value",
-		"tencent_cloud ddns value")
+		"endpoint address").SetValidate("omitempty,url")
+	DdnsInterval = cfg.NewFlag[time.Duration]("interval", "ddns.interval",
+		"monitor interval after a successful sync").SetDefault(20 * time.Minute).SetValidate("min=1m")
+	DdnsRetryInterval = cfg.NewFlag[time.Duration]("retry-interval", "ddns.retry_interval",
+		"first retry interval after a failed sync, doubled on each failure up to ddns.interval").
+		SetDefault(time.Minute).SetValidate("min=1s")
+
+	DdnsProvider = cfg.NewFlag[string]("provider", "ddns.provider",
+		"dns provider").SetDefault(ddns.ProviderDnspod).
+		SetValidate("oneof=dnspod cloudflare alidns rfc2136")
+	DdnsDomain = cfg.NewFlag[string]("domain", "ddns.domain",
+		"ddns domain, ignored if ddns.records is set").SetValidate("omitempty,fqdn")
+	DdnsSubDomain = cfg.NewFlag[string]("sub-domain", "ddns.sub_domain",
+		"ddns sub domain, @ for the domain itself").SetDefault("@").SetValidate("required")
+	DdnsTTL = cfg.NewFlag[int]("ttl", "ddns.ttl",
+		"ddns record ttl in seconds, 0 for provider default").SetValidate("min=0")
+	DdnsCreate = cfg.NewFlag[bool]("create", "ddns.create",
+		"create the record if it does not exist")
+	// ddns.value 未配置时使用旧版的 ddns.tencent_cloud.value，见 refreshValue
+	DdnsValue = cfg.NewFlag[string]("value", "ddns.value",
+		"ddns value").SetValidate("omitempty,ip")
 )
 
 func NewCmd() *cobra.Command {
@@ -52,31 +79,83 @@ func NewCmd() *cobra.Command {
 		},
 	}
 	DdnsListen.Bind(serverCmd)
+	DdnsAdminListen.Bind(serverCmd)
+	DdnsTrustedProxies.Bind(serverCmd)
+	DdnsProxyProtocol.Bind(serverCmd)
+	DdnsAllowCidrs.Bind(serverCmd)
+	DdnsRateLimit.Bind(serverCmd)
+	DdnsRateBurst.Bind(serverCmd)
+	cmdutil.BindAuth(serverCmd)
 
+	var once bool
 	monitorCmd := &cobra.Command{
 		Use: "monitor",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return monitor(DdnsEndpoint.Get())
+			// 收到 SIGTERM 或 SIGINT 后等待当前同步结束再退出，以便保存状态
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, syscall.SIGINT)
+			defer stop()
+			if !once {
+				err := cfg.Watch(ctx, cmd)
+				if err != nil {
+					return errutil.Wrap(err)
+				}
+			}
+			s, err := newSyncer()
+			if err != nil {
+				return err
+			}
+			closeStore := setupSyncer(s)
+			defer closeStore()
+			return monitor(ctx, s, once)
 		},
 	}
 	DdnsEndpoint.Bind(monitorCmd)
 	DdnsInterval.Bind(monitorCmd)
-	addTencentCloudDdnsFlags(monitorCmd)
+	DdnsRetryInterval.Bind(monitorCmd)
+	DdnsIpv4.Bind(monitorCmd)
+	DdnsIpv6.Bind(monitorCmd)
+	cmdutil.BindAuth(monitorCmd)
+	addRecordFlags(monitorCmd)
+	addStateFlags(monitorCmd)
+	cfg.RegisterStruct(monitorCmd, recordsKey, checkRecords)
+	cfg.RegisterStruct(monitorCmd, DdnsIpv4.sourcesKey(), DdnsIpv4.checkSources)
+	cfg.RegisterStruct(monitorCmd, DdnsIpv6.sourcesKey(), DdnsIpv6.checkSources)
+	monitorCmd.Flags().BoolVar(&once, "once", false, "sync once and exit, for use from external schedulers")
 
 	refreshCmd := &cobra.Command{
 		Use: "refresh",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return refresh(DdnsTcValue.Get())
+			s, err := newSyncer()
+			if err != nil {
+				return err
+			}
+			value, err := refreshValue()
+			if err != nil {
+				return err
+			}
+			closeStore := setupSyncer(s)
+			defer closeStore()
+			return refresh(cmd.Context(), s, value)
 		},
 	}
-	DdnsTcValue.Bind(refreshCmd)
-	addTencentCloudDdnsFlags(refreshCmd)
+	DdnsValue.Bind(refreshCmd)
+	DdnsIpv4.RecordId.Bind(refreshCmd)
+	DdnsIpv6.RecordId.Bind(refreshCmd)
+	addRecordFlags(refreshCmd)
+	addStateFlags(refreshCmd)
+	cfg.RegisterStruct(refreshCmd, recordsKey, checkRecords)
+	cfg.RegisterStruct(refreshCmd, DdnsValue.Cfg, func() error {
+		_, err := refreshValue()
+		return err
+	})
 
 	installCmd := newInstallCmd()
 
 	cmd.AddCommand(serverCmd)
 	cmd.AddCommand(monitorCmd)
 	cmd.AddCommand(refreshCmd)
+	cmd.AddCommand(newStatusCmd())
+	cmd.AddCommand(newHistoryCmd())
 	cmd.AddCommand(installCmd)
 	return cmd
 }
@@ -107,72 +186,152 @@ func newInstallCmd() *cobra.Command {
 	return cmd
 }
 
-func addTencentCloudDdnsFlags(cmd *cobra.Command) {
-	DdnsTcSecretId.Bind(cmd)
-	DdnsTcSecretKey.Bind(cmd)
-	DdnsTcDomain.Bind(cmd)
-	DdnsTcSubDomain.Bind(cmd)
-	DdnsTcRecordId.Bind(cmd)
-	DdnsTcRecordLine.Bind(cmd)
+func addRecordFlags(cmd *cobra.Command) {
+	DdnsProvider.Bind(cmd)
+	DdnsDomain.Bind(cmd)
+	DdnsSubDomain.Bind(cmd)
+	DdnsTTL.Bind(cmd)
+	DdnsCreate.Bind(cmd)
 }
 
-func serve(listen string) error {
-	e := gin.Default()
-	ddns.LoadRouter(&e.RouterGroup)
-	log.Info("starting serv", "listen", listen)
-	return e.Run(listen)
+func addStateFlags(cmd *cobra.Command) {
+	DdnsStateFile.Bind(cmd)
+	DdnsWebhooks.Bind(cmd)
+	DdnsFailureThreshold.Bind(cmd)
 }
 
-func monitor(endpoint string) error {
-	interval := DdnsInterval.Get()
-	log.Info("starting monitor", "endpoint", endpoint, "interval", interval)
+func serve(listen string) error {
+	e := gin.New()
+	e.Use(middleware.RequestId(), gin.Logger(), middleware.Recovery(nil))
+	// 未配置时不信任任何代理，忽略 X-Forwarded-For 等请求头
+	trusted := DdnsTrustedProxies.Get()
+	err := e.SetTrustedProxies(trusted)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	// 依次检查来源地址、限流与认证，均依赖上面的可信代理配置获取客户端 IP
+	if cidrs := DdnsAllowCidrs.Get(); len(cidrs) > 0 {
+		ps, err := netutil.ParsePrefixes(cidrs)
+		if err != nil {
+			return err
+		}
+		e.Use(middleware.AllowIps(ps))
+	}
+	if rate := DdnsRateLimit.Get(); rate > 0 {
+		e.Use(middleware.RateLimit(middleware.RateLimitConfig{Rate: rate, Burst: DdnsRateBurst.Get()}))
+	}
+	auth, err := cmdutil.DdnsAuth().Middleware()
+	if err != nil {
+		return err
+	}
+	if auth != nil {
+		e.Use(auth)
+	}
+	ddns.LoadRouter(&e.RouterGroup)
 
-	// 失败时快循环，成功时慢循环
-	curMyIp := ""
-	for {
-		myIp, err := ddns.GetMyIp(endpoint)
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	if DdnsProxyProtocol.Get() {
+		if len(trusted) == 0 {
+			return errutil.WrapF("ddns.proxy_protocol requires ddns.trusted_proxies")
+		}
+		ps, err := netutil.ParsePrefixes(trusted)
 		if err != nil {
-			log.Error(err.Error())
-			time.Sleep(time.Minute)
-			continue
+			return err
 		}
+		l = &netutil.ProxyListener{Listener: l, Trusted: ps}
+	}
 
-		if myIp == curMyIp {
-			log.Debug("myIp has not changed, do nothing", "myIp", myIp)
-			time.Sleep(time.Duration(interval) * time.Minute)
-			continue
+	errCh := make(chan error, 2)
+	// 日志接口可读取日志并修改级别，只在单独的管理地址上提供，默认仅监听本机，与 /my_ip 使用相同的认证
+	adminListen := DdnsAdminListen.Get()
+	if adminListen != "" {
+		admin := gin.New()
+		admin.Use(middleware.RequestId(), gin.Logger(), middleware.Recovery(nil))
+		if auth != nil {
+			admin.Use(auth)
 		}
+		logapi.LoadRouter(&admin.RouterGroup)
+		go func() {
+			errCh <- errutil.Wrap(admin.Run(adminListen))
+		}()
+	}
+	log.Info("starting serv", "listen", listen, "admin_listen", adminListen, "trusted_proxies", trusted,
+		"proxy_protocol", DdnsProxyProtocol.Get(), "allow_cidrs", DdnsAllowCidrs.Get(),
+		"rate_limit", DdnsRateLimit.Get(), "auth", cmdutil.DdnsAuthType.Get())
+	go func() {
+		errCh <- errutil.Wrap(e.RunListener(l))
+	}()
+	return <-errCh
+}
+
+const monitorJob = "ddns_monitor"
+
+// monitorGrace 为收到 SIGTERM 后等待正在进行的同步完成的最长时间，短于 systemd 默认的 90s 停止超时
+const monitorGrace = 30 * time.Second
+
+// monitor 在 ctx 取消前定时同步，成功后间隔 ddns.interval，失败后从 ddns.retry_interval 开始指数退避。
+// once 为 true 时只同步一次并返回结果，供外部调度器使用
+func monitor(ctx context.Context, s *ddns.Syncer, once bool) error {
+	if len(enabledFamilies()) == 0 {
+		return errutil.WrapF("both ddns.ipv4.enable and ddns.ipv6.enable are false")
+	}
+	if once {
+		return syncOnce(ctx, s)
+	}
+	log.Info("starting monitor", "endpoint", DdnsEndpoint.Get(), "records", len(s.Status()),
+		"ipv4", DdnsIpv4.Enable.Get(), "ipv6", DdnsIpv6.Enable.Get(),
+		"interval", DdnsInterval.Get(), "retry_interval", DdnsRetryInterval.Get())
+
+	// 间隔在每次执行后重新读取，使配置重载后生效
+	done := registry.RegisterCronJobWithConfig(ctx, monitorJob, registry.CronJobConfig{
+		Interval:  DdnsInterval.Get,
+		Backoff:   DdnsRetryInterval.Get,
+		Immediate: true,
+		Grace:     monitorGrace,
+	}, func(ctx context.Context) error {
+		return syncOnce(ctx, s)
+	})
+	<-done
+	log.Info("monitor stopped")
+	return nil
+}
 
-		err = refresh(myIp)
+// syncOnce 检测各地址族的 IP 并同步记录。各地址族独立检测，各记录独立更新，
+// 值未变化的记录由 Syncer 跳过
+func syncOnce(ctx context.Context, s *ddns.Syncer) error {
+	var errs []error
+	ips := make(map[ddns.Family]string)
+	for _, f := range enabledFamilies() {
+		if !slices.Contains(s.Families(), f.family) {
+			continue
+		}
+		myIp, err := f.detect(ctx)
 		if err != nil {
-			log.Error(err.Error())
-			time.Sleep(time.Minute)
+			log.ErrorC(ctx, "get myIp failed", "family", f.family, "err", err)
+			errs = append(errs, err)
 			continue
 		}
-		curMyIp = myIp
-		time.Sleep(time.Duration(interval) * time.Minute)
+		ips[f.family] = myIp
+	}
+	if err := s.Sync(ctx, ips); err != nil {
+		errs = append(errs, err)
 	}
+	return errors.Join(errs...)
 }
 
-func refresh(myIp string) error {
-	log.Warn("begin refresh myIp", "myIp", myIp)
-	req := &tencentcloud.ModifyDynamicDNSRequest{
-		Domain:     DdnsTcDomain.Get(),
-		SubDomain:  DdnsTcSubDomain.Get(),
-		RecordId:   DdnsTcRecordId.Get(),
-		RecordLine: DdnsTcRecordLine.Get(),
-		Value:      myIp,
-	}
-	secret := &tencentcloud.Secret{
-		Id:  DdnsTcSecretId.Get(),
-		Key: DdnsTcSecretKey.Get(),
-	}
-	info, err := tencentcloud.ModifyDynamicDns(req, secret)
+// refresh 将 myIp 写入其地址族对应的所有记录
+func refresh(ctx context.Context, s *ddns.Syncer, myIp string) error {
+	f, err := familyOf(myIp)
 	if err != nil {
-		return errutil.Wrap(fmt.Errorf("tencentcloud.ModifyDynamicDns failed: info=%s, err=%w", info, err))
+		return err
 	}
-	log.Warn("refresh myIp success", "myIp", myIp)
-	return nil
+	if !slices.Contains(s.Families(), f.family) {
+		return errutil.WrapF("no %s record configured", f.family.RecordType())
+	}
+	return s.Sync(ctx, map[ddns.Family]string{f.family: myIp})
 }
 
 var (
@@ -0,0 +1,150 @@
+package ddnscmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/internal/cmd/cmdutil"
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// familyFlags 为单个地址族的配置，位于 ddns.ipv4 与 ddns.ipv6 下
+type familyFlags struct {
+	family       ddns.Family
+	Enable       *cfg.Flag[bool]
+	Source       *cfg.Flag[string]
+	Endpoint     *cfg.Flag[string]
+	Interface    *cfg.Flag[string]
+	Strategy     *cfg.Flag[string]
+	Quorum       *cfg.Flag[int]
+	AllowPrivate *cfg.Flag[bool]
+	RecordId     *cfg.Flag[string]
+}
+
+func newFamilyFlags(family ddns.Family, enable bool) *familyFlags {
+	name := string(family)
+	key := "ddns." + name
+	return &familyFlags{
+		family: family,
+		Enable: cfg.NewFlag[bool](name, key+".enable",
+			"update "+family.RecordType()+" record").SetDefault(enable),
+		Source: cfg.NewFlag[string](name+"-source", key+".source",
+			"how to get "+name+" address if "+key+".sources is empty, endpoint or interface").SetDefault(ddns.SourceEndpoint).
+			SetValidate("oneof=endpoint interface"),
+		Endpoint: cfg.NewFlag[string](name+"-endpoint", key+".endpoint",
+			"endpoint reachable over "+name+", ddns.endpoint if empty").SetValidate("omitempty,url"),
+		Interface: cfg.NewFlag[string](name+"-interface", key+".interface",
+			"interface to read "+name+" global address from, all interfaces if empty"),
+		Strategy: cfg.NewFlag[string](name+"-strategy", key+".strategy",
+			"how to combine "+key+".sources, first or quorum").SetDefault(ddns.StrategyFirst).
+			SetValidate("oneof=first quorum"),
+		Quorum: cfg.NewFlag[int](name+"-quorum", key+".quorum",
+			"sources that must agree with quorum strategy, 0 for majority").SetValidate("min=0"),
+		AllowPrivate: cfg.NewFlag[bool](name+"-allow-private", key+".allow_private",
+			"accept private and reserved "+name+" addresses"),
+		RecordId: cfg.NewFlag[string](name+"-record-id", key+".record_id",
+			family.RecordType()+" record id, looked up by name and cached if empty"),
+	}
+}
+
+var (
+	DdnsIpv4 = newFamilyFlags(ddns.FamilyIpv4, true)
+	DdnsIpv6 = newFamilyFlags(ddns.FamilyIpv6, false)
+)
+
+func (f *familyFlags) Bind(cmd *cobra.Command) {
+	f.Enable.Bind(cmd)
+	f.Source.Bind(cmd)
+	f.Endpoint.Bind(cmd)
+	f.Interface.Bind(cmd)
+	f.Strategy.Bind(cmd)
+	f.Quorum.Bind(cmd)
+	f.AllowPrivate.Bind(cmd)
+	f.RecordId.Bind(cmd)
+}
+
+// detect 获取当前的公网地址，每次重新读取来源配置以便配置重载后生效
+func (f *familyFlags) detect(ctx context.Context) (string, error) {
+	sources, err := f.sources()
+	if err != nil {
+		return "", err
+	}
+	d, err := ddns.NewDetector(ddns.DetectorConfig{
+		Strategy:     f.Strategy.Get(),
+		Quorum:       f.Quorum.Get(),
+		AllowPrivate: f.AllowPrivate.Get(),
+	}, sources...)
+	if err != nil {
+		return "", err
+	}
+	return d.Detect(ctx, f.family)
+}
+
+func (f *familyFlags) sourcesKey() string {
+	return "ddns." + string(f.family) + ".sources"
+}
+
+// checkSources 校验启用的地址族的来源配置
+func (f *familyFlags) checkSources() error {
+	if !f.Enable.Get() {
+		return nil
+	}
+	_, err := f.sources()
+	return err
+}
+
+// sources 读取 ddns.<family>.sources，未配置时按 source、endpoint 与 interface 创建单个来源
+func (f *familyFlags) sources() ([]ddns.Source, error) {
+	key := f.sourcesKey()
+	var cs []ddns.SourceConfig
+	if err := cfg.UnmarshalKey(key, &cs); err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		c := ddns.SourceConfig{Type: ddns.SourceInterface, Interface: f.Interface.Get()}
+		if f.Source.Get() != ddns.SourceInterface {
+			c = ddns.SourceConfig{Type: ddns.SourceEndpoint, Url: f.Endpoint.Get()}
+			if c.Url == "" {
+				c.Url = DdnsEndpoint.Get()
+			}
+			if c.Url == "" {
+				return nil, errutil.WrapF("neither ddns.%s.endpoint nor ddns.endpoint is set", f.family)
+			}
+		}
+		cs = append(cs, c)
+	}
+	var res []ddns.Source
+	for _, c := range cs {
+		c.Auth = cmdutil.DdnsAuth()
+		s, err := ddns.NewSource(c)
+		if err != nil {
+			return nil, errutil.WrapF("%s: %w", key, err)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// enabledFamilies 返回启用的地址族，每轮重新读取以便配置重载后生效
+func enabledFamilies() []*familyFlags {
+	var fs []*familyFlags
+	for _, f := range []*familyFlags{DdnsIpv4, DdnsIpv6} {
+		if f.Enable.Get() {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+func familyOf(ip string) (*familyFlags, error) {
+	typ, err := ddns.RecordType(ip)
+	if err != nil {
+		return nil, err
+	}
+	if typ == ddns.TypeAAAA {
+		return DdnsIpv6, nil
+	}
+	return DdnsIpv4, nil
+}
@@ -0,0 +1,69 @@
+package ddnscmd
+
+import (
+	"sync"
+
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// 旧版本在 ddns.tencent_cloud 下配置单条记录，升级后映射到新的配置项并提示迁移。
+// 这些 Flag 不绑定到命令，只用于读取配置文件
+var (
+	legacyDomain    = cfg.NewFlag[string]("", dnspodKey+".domain", "deprecated, use ddns.domain")
+	legacySubDomain = cfg.NewFlag[string]("", dnspodKey+".sub_domain", "deprecated, use ddns.sub_domain")
+	legacyRecordId  = cfg.NewFlag[string]("", dnspodKey+".record_id", "deprecated, use ddns.ipv4.record_id")
+	legacyValue     = cfg.NewFlag[string]("", dnspodKey+".value", "deprecated, use ddns.value")
+)
+
+// gLegacyWarn 与 gLegacyValueWarn 使迁移提示每个进程只输出一次
+var gLegacyWarn, gLegacyValueWarn sync.Once
+
+// legacyRecord 在未配置 ddns.domain 时读取旧版的记录配置，返回域名、主机记录与 A 记录 ID，
+// 未配置旧版的域名时返回空域名
+func legacyRecord() (string, string, string, error) {
+	domain := legacyDomain.Get()
+	if domain == "" {
+		return "", "", "", nil
+	}
+	if DdnsProvider.Get() != ddns.ProviderDnspod {
+		return "", "", "", errutil.WrapF("%s is deprecated and only applies to provider dnspod, use %s instead",
+			legacyDomain.Cfg, DdnsDomain.Cfg)
+	}
+	subDomain := legacySubDomain.Get()
+	if subDomain == "" {
+		subDomain = "@"
+	}
+	gLegacyWarn.Do(func() {
+		log.Warn("deprecated config, please migrate",
+			legacyDomain.Cfg, DdnsDomain.Cfg, legacySubDomain.Cfg, DdnsSubDomain.Cfg,
+			legacyRecordId.Cfg, DdnsIpv4.RecordId.Cfg)
+	})
+	return domain, subDomain, legacyRecordId.Get(), nil
+}
+
+// warnLegacyIgnored 在 key 已配置时提示旧版的记录配置不再生效
+func warnLegacyIgnored(key string) {
+	if legacyDomain.Get() == "" {
+		return
+	}
+	gLegacyWarn.Do(func() {
+		log.Warn("deprecated config is ignored since "+key+" is set, please remove it", "key", legacyDomain.Cfg)
+	})
+}
+
+// refreshValue 返回 ddns.value，未配置时使用旧版的 ddns.tencent_cloud.value
+func refreshValue() (string, error) {
+	if v := DdnsValue.Get(); v != "" {
+		return v, nil
+	}
+	if v := legacyValue.Get(); v != "" {
+		gLegacyValueWarn.Do(func() {
+			log.Warn("deprecated config, please migrate", legacyValue.Cfg, DdnsValue.Cfg)
+		})
+		return v, nil
+	}
+	return "", errutil.WrapF("%s is required", DdnsValue.Cfg)
+}
@@ -0,0 +1,116 @@
+package ddnscmd
+
+import (
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// DNSPod 沿用原有的 ddns.tencent_cloud 配置
+const (
+	dnspodKey     = "ddns.tencent_cloud"
+	cloudflareKey = "ddns.cloudflare"
+	alidnsKey     = "ddns.alidns"
+	rfc2136Key    = "ddns.rfc2136"
+
+	// accountsKey 下每项为一个服务商账号，provider 指定服务商，其余为该服务商的配置
+	accountsKey = "ddns.accounts"
+)
+
+func init() {
+	cfg.MarkSecret(
+		dnspodKey+".secret_id",
+		dnspodKey+".secret_key",
+		cloudflareKey+".api_token",
+		alidnsKey+".access_key_secret",
+		rfc2136Key+".tsig_secret",
+		accountsKey+".*.secret_id",
+		accountsKey+".*.secret_key",
+		accountsKey+".*.api_token",
+		accountsKey+".*.access_key_secret",
+		accountsKey+".*.tsig_secret",
+	)
+}
+
+type account struct {
+	Provider string `mapstructure:"provider" validate:"required,oneof=dnspod cloudflare alidns rfc2136"`
+}
+
+// newProvider 按 ddns.provider 创建服务商，并校验其配置
+func newProvider() (ddns.Provider, error) {
+	switch name := DdnsProvider.Get(); name {
+	case ddns.ProviderDnspod:
+		return newProviderAt(name, dnspodKey)
+	case ddns.ProviderCloudflare:
+		return newProviderAt(name, cloudflareKey)
+	case ddns.ProviderAlidns:
+		return newProviderAt(name, alidnsKey)
+	case ddns.ProviderRfc2136:
+		return newProviderAt(name, rfc2136Key)
+	default:
+		return nil, errutil.WrapF("unknown ddns provider %q", name)
+	}
+}
+
+// newProviders 创建 targets 引用的所有账号，空账号对应 ddns.provider
+func newProviders(targets []ddns.Target) (map[string]ddns.Provider, error) {
+	ps := make(map[string]ddns.Provider)
+	for _, t := range targets {
+		if _, ok := ps[t.Account]; ok {
+			continue
+		}
+		var p ddns.Provider
+		var err error
+		if t.Account == "" {
+			p, err = newProvider()
+		} else {
+			p, err = newAccount(t.Account)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ps[t.Account] = p
+	}
+	return ps, nil
+}
+
+func newAccount(name string) (ddns.Provider, error) {
+	key := accountsKey + "." + name
+	var a account
+	if err := cfg.UnmarshalKey(key, &a); err != nil {
+		return nil, err
+	}
+	return newProviderAt(a.Provider, key)
+}
+
+// newProviderAt 从 key 下读取服务商 name 的配置
+func newProviderAt(name, key string) (ddns.Provider, error) {
+	switch name {
+	case ddns.ProviderDnspod:
+		var c ddns.DnspodConfig
+		if err := cfg.UnmarshalKey(key, &c); err != nil {
+			return nil, err
+		}
+		return ddns.NewDnspod(c)
+	case ddns.ProviderCloudflare:
+		var c ddns.CloudflareConfig
+		if err := cfg.UnmarshalKey(key, &c); err != nil {
+			return nil, err
+		}
+		return ddns.NewCloudflare(c)
+	case ddns.ProviderAlidns:
+		var c ddns.AlidnsConfig
+		if err := cfg.UnmarshalKey(key, &c); err != nil {
+			return nil, err
+		}
+		return ddns.NewAlidns(c)
+	case ddns.ProviderRfc2136:
+		var c ddns.Rfc2136Config
+		if err := cfg.UnmarshalKey(key, &c); err != nil {
+			return nil, err
+		}
+		return ddns.NewRfc2136(c)
+	default:
+		return nil, errutil.WrapF("unknown ddns provider %q", name)
+	}
+}
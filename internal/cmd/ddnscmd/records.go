@@ -0,0 +1,72 @@
+package ddnscmd
+
+import (
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const recordsKey = "ddns.records"
+
+// loadTargets 读取 ddns.records，未配置时使用 ddns.domain 等单条记录的配置
+func loadTargets() ([]ddns.Target, error) {
+	var ts []ddns.Target
+	if err := cfg.UnmarshalKey(recordsKey, &ts); err != nil {
+		return nil, err
+	}
+	if len(ts) > 0 {
+		warnLegacyIgnored(recordsKey)
+		return ts, nil
+	}
+	domain, subDomain, ipv4Id := DdnsDomain.Get(), DdnsSubDomain.Get(), DdnsIpv4.RecordId.Get()
+	if domain != "" {
+		warnLegacyIgnored(DdnsDomain.Cfg)
+	} else {
+		var legacyId string
+		var err error
+		domain, subDomain, legacyId, err = legacyRecord()
+		if err != nil {
+			return nil, err
+		}
+		if ipv4Id == "" {
+			ipv4Id = legacyId
+		}
+	}
+	if domain == "" {
+		return nil, errutil.WrapF("neither %s nor ddns.domain is set", recordsKey)
+	}
+	var res []ddns.Target
+	for _, f := range []*familyFlags{DdnsIpv4, DdnsIpv6} {
+		id := f.RecordId.Get()
+		if f == DdnsIpv4 {
+			id = ipv4Id
+		}
+		res = append(res, ddns.Target{
+			Domain:   domain,
+			Name:     subDomain,
+			Type:     f.family.RecordType(),
+			TTL:      DdnsTTL.Get(),
+			RecordId: id,
+			Create:   DdnsCreate.Get(),
+		})
+	}
+	return res, nil
+}
+
+func newSyncer() (*ddns.Syncer, error) {
+	ts, err := loadTargets()
+	if err != nil {
+		return nil, err
+	}
+	ps, err := newProviders(ts)
+	if err != nil {
+		return nil, err
+	}
+	return ddns.NewSyncer(ps, ts)
+}
+
+// checkRecords 校验记录与其引用的服务商账号，供 config validate 使用
+func checkRecords() error {
+	_, err := newSyncer()
+	return err
+}
@@ -0,0 +1,126 @@
+package ddnscmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/internal/constant"
+	"github.com/vksir/vkiss-lib/internal/ddns"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+var (
+	DdnsStateFile = cfg.NewFlag[string]("state-file", "ddns.state_file",
+		"sqlite file keeping record state and history, disabled if empty").
+		SetDefault(filepath.Join(constant.DataDir, "ddns.db"))
+	DdnsWebhooks = cfg.NewFlag[[]string]("webhooks", "ddns.notify.webhooks",
+		"urls to post record changes and failures to").SetValidate("dive,url")
+	DdnsFailureThreshold = cfg.NewFlag[int]("failure-threshold", "ddns.notify.failure_threshold",
+		"notify after this many consecutive failures of a record, 0 to disable").SetDefault(3).
+		SetValidate("min=0")
+)
+
+// setupSyncer 为 s 打开状态文件并配置通知，返回的 close 用于关闭状态文件。
+// 状态文件无法打开 (如非 root 用户无权写默认路径) 时记录日志并在不保存状态的情况下继续
+func setupSyncer(s *ddns.Syncer) func() {
+	if hooks := DdnsWebhooks.Get(); len(hooks) > 0 {
+		var ns ddns.Notifiers
+		for _, u := range hooks {
+			ns = append(ns, ddns.NewWebhook(u))
+		}
+		s.SetNotifier(ns, DdnsFailureThreshold.Get())
+	}
+	path := DdnsStateFile.Get()
+	if path == "" {
+		return func() {}
+	}
+	store, err := ddns.OpenStore(path)
+	if err == nil {
+		err = s.SetStore(store)
+		if err != nil {
+			_ = store.Close()
+		}
+	}
+	if err != nil {
+		log.Warn("open state file failed, continue without persisting state",
+			"state_file", path, "err", err, "hint", "set ddns.state_file to a writable path or empty to disable")
+		return func() {}
+	}
+	return func() { _ = store.Close() }
+}
+
+// openStore 以只读方式打开状态文件，不存在时报错而不创建
+func openStore() (*ddns.Store, error) {
+	if DdnsStateFile.Get() == "" {
+		return nil, errutil.WrapF("ddns.state_file is not set")
+	}
+	return ddns.OpenStoreReadOnly(DdnsStateFile.Get())
+}
+
+func newStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the last sync result of each record",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			states, err := store.States()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-40s %-40s %-20s %-20s %-8s %s\n", "RECORD", "VALUE", "SYNCED", "CHECKED", "FAILURES", "ERROR")
+			for _, st := range states {
+				fmt.Printf("%-40s %-40s %-20s %-20s %-8d %s\n",
+					st.Key, st.Value, formatTime(st.Synced), formatTime(st.Checked), st.Failures, st.Err)
+			}
+			return nil
+		},
+	}
+	DdnsStateFile.Bind(cmd)
+	return cmd
+}
+
+func newHistoryCmd() *cobra.Command {
+	var record string
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show recent value changes of records",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			changes, err := store.History(record, limit)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%-20s %-40s %-40s %s\n", "TIME", "RECORD", "OLD", "NEW")
+			for _, c := range changes {
+				fmt.Printf("%-20s %-40s %-40s %s\n", formatTime(c.Time), c.Key, c.Old, c.New)
+			}
+			return nil
+		},
+	}
+	DdnsStateFile.Bind(cmd)
+	cmd.Flags().StringVar(&record, "record", "", "only show changes of this record, as shown by ddns status")
+	cmd.Flags().IntVar(&limit, "limit", 20, "max changes to show, 0 for all")
+	return cmd
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
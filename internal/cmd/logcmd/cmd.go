@@ -0,0 +1,75 @@
+package logcmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/internal/cmd/cmdutil"
+	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/log/logapi"
+)
+
+var (
+	LogEndpoint = cfg.NewFlag[string]("endpoint", "log.endpoint",
+		"admin endpoint of running server, see ddns.admin_listen").SetDefault("http://127.0.0.1:5802").
+		SetValidate("required,url")
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Manage log of running server",
+	}
+
+	levelsCmd := &cobra.Command{
+		Use:   "levels",
+		Short: "List log levels of all modules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var modules []log.ModuleLevel
+			err := request(cmd.Context(), http.MethodGet, "/log/levels", nil, &modules)
+			if err != nil {
+				return err
+			}
+			for _, m := range modules {
+				fmt.Printf("%-32s %-8s %s\n", m.Name, m.Effective, m.Level)
+			}
+			return nil
+		},
+	}
+	LogEndpoint.Bind(levelsCmd)
+	cmdutil.BindAuth(levelsCmd)
+
+	setLevelCmd := &cobra.Command{
+		Use:   "set-level <module> [level]",
+		Short: "Set log level of a module, inherit from parent if level is empty",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := logapi.SetLevelRequest{}
+			if len(args) > 1 {
+				req.Level = args[1]
+			}
+			return request(cmd.Context(), http.MethodPut, "/log/levels/"+url.PathEscape(args[0]), req, nil)
+		},
+	}
+	LogEndpoint.Bind(setLevelCmd)
+	cmdutil.BindAuth(setLevelCmd)
+
+	cmd.AddCommand(levelsCmd)
+	cmd.AddCommand(setLevelCmd)
+	return cmd
+}
+
+func request(ctx context.Context, method, path string, body any, data any) error {
+	c := httpclient.New(httpclient.Config{
+		BaseUrl:    LogEndpoint.Get(),
+		RetryCount: -1,
+		Transport:  cmdutil.DdnsAuth().Transport(LogEndpoint.Get(), nil),
+	})
+	return c.Call(ctx, method, path, body, data)
+}
@@ -2,19 +2,48 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/vksir/vkiss-lib/assets"
+	"github.com/vksir/vkiss-lib/internal/cmd/configcmd"
 	"github.com/vksir/vkiss-lib/internal/cmd/ddnscmd"
+	"github.com/vksir/vkiss-lib/internal/cmd/logcmd"
+	"github.com/vksir/vkiss-lib/internal/constant"
 	"github.com/vksir/vkiss-lib/pkg/cfg"
+	"github.com/vksir/vkiss-lib/pkg/log"
 )
 
 func NewRootCmd() *cobra.Command {
+	var cfgFile string
 	root := &cobra.Command{
 		Use:   "vkiss",
 		Short: "Vkiss Tool",
 		Long:  `Vkiss Tool`,
+		// 子命令可以覆盖 PersistentPreRunE 以跳过读取配置，如 config init
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Init(cfgFile, assets.DefaultConfig)
+			log.InitConfig(cfg.LogConfig())
+			return cfg.Validate(cmd)
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
 	}
+	root.PersistentFlags().StringVarP(&cfgFile, "config", "c", constant.ConfPath,
+		"config file")
 
 	root.AddCommand(ddnscmd.NewCmd())
+	root.AddCommand(configcmd.NewCmd())
+	root.AddCommand(logcmd.NewCmd())
 	cfg.LogPath.Bind(root)
 	cfg.LogLevel.Bind(root)
+	cfg.LogMaxSize.Bind(root)
+	cfg.LogRotateInterval.Bind(root)
+	cfg.LogMaxBackups.Bind(root)
+	cfg.LogMaxAge.Bind(root)
+	cfg.LogCompress.Bind(root)
+	cfg.LogFormat.Bind(root)
+	cfg.LogConsoleLevel.Bind(root)
+	cfg.LogFileLevel.Bind(root)
+	cfg.LogModules.Bind(root)
+	cfg.LogRingSize.Bind(root)
+	cfg.SecretKeyFile.Bind(root)
 	return root
 }
@@ -3,4 +3,5 @@ package constant
 const (
 	ConfPath = "/etc/vkiss/config.toml"
 	ExePath  = "/bin/vkiss"
+	DataDir  = "/var/lib/vkiss"
 )
@@ -0,0 +1,186 @@
+package ddns
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const defaultAlidnsEndpoint = "https://alidns.aliyuncs.com"
+
+type AlidnsConfig struct {
+	AccessKeyId     string `mapstructure:"access_key_id" validate:"required"`
+	AccessKeySecret string `mapstructure:"access_key_secret" validate:"required"`
+	Endpoint        string `mapstructure:"endpoint" validate:"omitempty,url"`
+}
+
+// Alidns 使用阿里云云解析 DNS 的 RPC 接口 (2015-01-09)，签名方式为 HMAC-SHA1
+type Alidns struct {
+	client *httpclient.Client
+	id     string
+	secret string
+	now    func() time.Time
+}
+
+func NewAlidns(c AlidnsConfig) (*Alidns, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAlidnsEndpoint
+	}
+	return &Alidns{
+		client: httpclient.New(httpclient.Config{BaseUrl: endpoint}),
+		id:     c.AccessKeyId,
+		secret: c.AccessKeySecret,
+		now:    time.Now,
+	}, nil
+}
+
+type alidnsRecord struct {
+	RecordId   string `json:"RecordId"`
+	DomainName string `json:"DomainName"`
+	RR         string `json:"RR"`
+	Type       string `json:"Type"`
+	Value      string `json:"Value"`
+	TTL        int    `json:"TTL"`
+}
+
+func (p *Alidns) GetRecord(ctx context.Context, key Record) (Record, error) {
+	r := Record{Domain: key.Domain, Name: subName(key.Name), Type: key.Type}
+	var resp struct {
+		DomainRecords struct {
+			Record []alidnsRecord `json:"Record"`
+		} `json:"DomainRecords"`
+	}
+	err := p.call(ctx, "DescribeSubDomainRecords", map[string]string{
+		"SubDomain":  r.Fqdn(),
+		"DomainName": r.Domain,
+		"Type":       r.Type,
+	}, &resp)
+	if err != nil {
+		return Record{}, err
+	}
+	for _, item := range resp.DomainRecords.Record {
+		if item.Type != r.Type {
+			continue
+		}
+		r.Id = item.RecordId
+		r.Value = item.Value
+		r.TTL = item.TTL
+		return r, nil
+	}
+	return Record{}, notFound(r)
+}
+
+func (p *Alidns) UpsertRecord(ctx context.Context, r Record) (Record, error) {
+	r.Name = subName(r.Name)
+	return upsert(ctx, p, r, p.create, p.modify)
+}
+
+func (p *Alidns) create(ctx context.Context, r Record) (Record, error) {
+	params := p.recordParams(r)
+	params["DomainName"] = r.Domain
+	var resp struct {
+		RecordId string `json:"RecordId"`
+	}
+	// 阿里云的接口均以 GET 调用，创建不可重试，避免重复添加记录
+	err := p.call(httpclient.WithRetry(ctx, false), "AddDomainRecord", params, &resp)
+	if err != nil {
+		return Record{}, err
+	}
+	r.Id = resp.RecordId
+	return r, nil
+}
+
+func (p *Alidns) modify(ctx context.Context, r Record) (Record, error) {
+	params := p.recordParams(r)
+	params["RecordId"] = r.Id
+	err := p.call(ctx, "UpdateDomainRecord", params, nil)
+	// 记录 ID 不存在时阿里云返回 DomainRecordNotBelongToUser
+	if err != nil && strings.Contains(err.Error(), "DomainRecordNotBelongToUser") {
+		return Record{}, errutil.WrapNotFound(r.Type + " record " + r.Id)
+	}
+	// 值未变化时阿里云返回 DomainRecordDuplicate，视为成功
+	if err != nil && !strings.Contains(err.Error(), "DomainRecordDuplicate") {
+		return Record{}, err
+	}
+	return r, nil
+}
+
+func (p *Alidns) recordParams(r Record) map[string]string {
+	params := map[string]string{
+		"RR":    r.Name,
+		"Type":  r.Type,
+		"Value": r.Value,
+	}
+	if r.TTL > 0 {
+		params["TTL"] = strconv.Itoa(r.TTL)
+	}
+	return params
+}
+
+func (p *Alidns) DeleteRecord(ctx context.Context, r Record) error {
+	r.Name = subName(r.Name)
+	id, err := lookupId(ctx, p, r)
+	if err != nil || id == "" {
+		return err
+	}
+	return p.call(ctx, "DeleteDomainRecord", map[string]string{"RecordId": id}, nil)
+}
+
+// call 发起签名后的 RPC 请求，见 https://help.aliyun.com/document_detail/29747.html
+func (p *Alidns) call(ctx context.Context, action string, params map[string]string, v any) error {
+	query := map[string]string{
+		"Action":           action,
+		"Format":           "JSON",
+		"Version":          "2015-01-09",
+		"AccessKeyId":      p.id,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   uuid.NewString(),
+		"Timestamp":        p.now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for k, val := range params {
+		query[k] = val
+	}
+	query["Signature"] = alidnsSign(http.MethodGet, query, p.secret)
+
+	err := p.client.Get(ctx, "/", query, v)
+	if err != nil {
+		return errutil.WrapF("alidns %s failed: %w", action, err)
+	}
+	return nil
+}
+
+func alidnsSign(method string, query map[string]string, secret string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, alidnsEscape(k)+"="+alidnsEscape(query[k]))
+	}
+	s := method + "&" + alidnsEscape("/") + "&" + alidnsEscape(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func alidnsEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
@@ -0,0 +1,88 @@
+package ddns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAlidnsServer 模拟阿里云云解析 DNS 的 RPC 接口，并校验签名
+func newAlidnsServer(t *testing.T, zone *fakeZone, secret string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := func(v any) { _ = json.NewEncoder(w).Encode(v) }
+		fail := func(code string) {
+			w.WriteHeader(http.StatusBadRequest)
+			reply(map[string]string{"Code": code, "Message": code})
+		}
+
+		q := r.URL.Query()
+		query := make(map[string]string, len(q))
+		for k := range q {
+			query[k] = q.Get(k)
+		}
+		sig := query["Signature"]
+		delete(query, "Signature")
+		if sig != alidnsSign(r.Method, query, secret) {
+			fail("SignatureDoesNotMatch")
+			return
+		}
+
+		ttl, _ := strconv.Atoi(query["TTL"])
+		rec := Record{Name: query["RR"], Type: query["Type"], Value: query["Value"], TTL: ttl}
+		switch query["Action"] {
+		case "DescribeSubDomainRecords":
+			name := strings.TrimSuffix(strings.TrimSuffix(query["SubDomain"], query["DomainName"]), ".")
+			if name == "" {
+				name = "@"
+			}
+			var records []alidnsRecord
+			if cur, ok := zone.get(name, query["Type"]); ok {
+				records = append(records, alidnsRecord{RecordId: cur.Id, RR: cur.Name, Type: cur.Type, Value: cur.Value, TTL: cur.TTL})
+			}
+			reply(map[string]any{"TotalCount": len(records), "DomainRecords": map[string]any{"Record": records}})
+		case "AddDomainRecord":
+			reply(map[string]string{"RecordId": zone.create(rec).Id})
+		case "UpdateDomainRecord":
+			if cur, ok := zone.get(rec.Name, rec.Type); ok && cur.Id == query["RecordId"] && cur.Value == rec.Value {
+				fail("DomainRecordDuplicate")
+				return
+			}
+			if !zone.modify(query["RecordId"], rec) {
+				fail("DomainRecordNotBelongToUser")
+				return
+			}
+			reply(map[string]string{"RecordId": query["RecordId"]})
+		case "DeleteDomainRecord":
+			if !zone.delete(query["RecordId"]) {
+				fail("DomainRecordNotBelongToUser")
+				return
+			}
+			reply(map[string]string{"RecordId": query["RecordId"]})
+		default:
+			fail("InvalidAction")
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestAlidns(t *testing.T) {
+	s := newAlidnsServer(t, newFakeZone(), "secret")
+	p, err := NewAlidns(AlidnsConfig{AccessKeyId: "id", AccessKeySecret: "secret", Endpoint: s.URL})
+	require.NoError(t, err)
+	testProvider(t, p, "example.com", "home")
+
+	// 值未变化时视为成功
+	_, err = p.UpsertRecord(t.Context(), Record{Domain: "example.com", Name: "home", Type: TypeAAAA, Value: "2001:db8::1", TTL: 600})
+	require.NoError(t, err)
+
+	p, err = NewAlidns(AlidnsConfig{AccessKeyId: "id", AccessKeySecret: "wrong", Endpoint: s.URL})
+	require.NoError(t, err)
+	_, err = p.GetRecord(t.Context(), Record{Domain: "example.com", Name: "home", Type: TypeA})
+	require.ErrorContains(t, err, "SignatureDoesNotMatch")
+}
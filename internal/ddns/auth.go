@@ -0,0 +1,97 @@
+package ddns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/middleware"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// ddns server 与 monitor 之间的认证方式，对应配置 ddns.auth.type
+const (
+	AuthNone = "none"
+	// AuthToken 以 Authorization: Bearer 发送共享的 token
+	AuthToken = "token"
+	// AuthHmac 以共享的密钥签名请求，见 middleware.HmacSign
+	AuthHmac = "hmac"
+)
+
+// Auth 为 ddns server 与 monitor 之间的认证配置，两端需一致
+type Auth struct {
+	Type   string
+	Secret string
+}
+
+func (a Auth) enabled() bool {
+	return a.Type != "" && a.Type != AuthNone
+}
+
+// Middleware 返回服务端的认证中间件，未启用认证时返回 nil
+func (a Auth) Middleware() (gin.HandlerFunc, error) {
+	if !a.enabled() {
+		return nil, nil
+	}
+	if a.Secret == "" {
+		return nil, errutil.WrapF("ddns auth %s requires secret", a.Type)
+	}
+	switch a.Type {
+	case AuthToken:
+		return middleware.TokenAuth(a.Secret), nil
+	case AuthHmac:
+		return middleware.HmacAuth(a.Secret, 0), nil
+	default:
+		return nil, errutil.WrapF("unknown ddns auth type %q", a.Type)
+	}
+}
+
+// Transport 返回在请求上添加认证信息的 http.RoundTripper，未启用认证时返回 base，
+// base 为空时使用 http.DefaultTransport。HMAC 签名的路径相对于 baseUrl 的路径
+func (a Auth) Transport(baseUrl string, base http.RoundTripper) http.RoundTripper {
+	if !a.enabled() {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var prefix string
+	if u, err := url.Parse(baseUrl); err == nil {
+		prefix = strings.TrimSuffix(u.Path, "/")
+	}
+	return &authTransport{base: base, auth: a, prefix: prefix}
+}
+
+type authTransport struct {
+	base   http.RoundTripper
+	auth   Auth
+	prefix string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	switch t.auth.Type {
+	case AuthToken:
+		req.Header.Set("Authorization", "Bearer "+t.auth.Secret)
+	case AuthHmac:
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errutil.Wrap(err)
+		}
+		nonce := hex.EncodeToString(b)
+		path := req.URL.Path
+		if rel, ok := strings.CutPrefix(path, t.prefix); ok && strings.HasPrefix(rel, "/") {
+			path = rel
+		}
+		ts := time.Now().Unix()
+		req.Header.Set(middleware.HeaderTimestamp, strconv.FormatInt(ts, 10))
+		req.Header.Set(middleware.HeaderNonce, nonce)
+		req.Header.Set(middleware.HeaderSignature, middleware.HmacSign(t.auth.Secret, req.Method, path, ts, nonce))
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,53 @@
+package ddns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+)
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, typ := range []string{AuthToken, AuthHmac} {
+		m, err := Auth{Type: typ, Secret: "s3cret"}.Middleware()
+		require.NoError(t, err)
+		e := gin.New()
+		e.Use(m)
+		LoadRouter(&e.RouterGroup)
+		s := httptest.NewServer(e)
+
+		ip, err := getMyIpAuth(context.Background(), s.URL, FamilyIpv4, Auth{Type: typ, Secret: "s3cret"})
+		require.NoError(t, err, typ)
+		assert.Equal(t, "127.0.0.1", ip)
+
+		_, err = getMyIpAuth(context.Background(), s.URL, FamilyIpv4, Auth{Type: typ, Secret: "wrong"})
+		assert.True(t, httpclient.IsStatus(err, 401), "%s: %v", typ, err)
+		_, err = GetMyIpFamily(context.Background(), s.URL, FamilyIpv4)
+		assert.True(t, httpclient.IsStatus(err, 401), "%s: %v", typ, err)
+		s.Close()
+	}
+
+	// 经过去掉路径前缀的反向代理时，签名使用相对 endpoint 的路径
+	m, err := Auth{Type: AuthHmac, Secret: "s3cret"}.Middleware()
+	require.NoError(t, err)
+	e := gin.New()
+	e.Use(m)
+	LoadRouter(&e.RouterGroup)
+	s := httptest.NewServer(http.StripPrefix("/ddns", e))
+	defer s.Close()
+	ip, err := getMyIpAuth(context.Background(), s.URL+"/ddns/", FamilyIpv4, Auth{Type: AuthHmac, Secret: "s3cret"})
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip)
+
+	m, err = Auth{Type: AuthNone}.Middleware()
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+	_, err = Auth{Type: AuthHmac}.Middleware()
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const defaultCloudflareBaseUrl = "https://api.cloudflare.com/client/v4"
+
+type CloudflareConfig struct {
+	// ApiToken 需要 Zone.DNS 的编辑权限
+	ApiToken string `mapstructure:"api_token" validate:"required"`
+	// ZoneId 为空时按域名查找
+	ZoneId  string `mapstructure:"zone_id"`
+	Proxied bool   `mapstructure:"proxied"`
+	BaseUrl string `mapstructure:"base_url" validate:"omitempty,url"`
+}
+
+// Cloudflare 使用 Cloudflare API v4
+type Cloudflare struct {
+	client  *httpclient.Client
+	proxied bool
+
+	lock  sync.Mutex
+	zones map[string]string
+}
+
+func NewCloudflare(c CloudflareConfig) (*Cloudflare, error) {
+	baseUrl := c.BaseUrl
+	if baseUrl == "" {
+		baseUrl = defaultCloudflareBaseUrl
+	}
+	p := &Cloudflare{
+		client: httpclient.New(httpclient.Config{
+			BaseUrl: baseUrl,
+			Headers: map[string]string{"Authorization": "Bearer " + c.ApiToken},
+		}),
+		proxied: c.Proxied,
+		zones:   make(map[string]string),
+	}
+	if c.ZoneId != "" {
+		p.zones[""] = c.ZoneId
+	}
+	return p, nil
+}
+
+type cloudflareResponse[T any] struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result T `json:"result"`
+}
+
+func (r *cloudflareResponse[T]) err() error {
+	if r.Success {
+		return nil
+	}
+	msgs := make([]string, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		msgs = append(msgs, fmt.Sprintf("%d: %s", e.Code, e.Message))
+	}
+	return errutil.WrapF("cloudflare request failed: %s", strings.Join(msgs, "; "))
+}
+
+type cloudflareRecord struct {
+	Id      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+	Proxied bool   `json:"proxied"`
+}
+
+func (p *Cloudflare) zoneId(ctx context.Context, domain string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if id, ok := p.zones[""]; ok {
+		return id, nil
+	}
+	if id, ok := p.zones[domain]; ok {
+		return id, nil
+	}
+	var resp cloudflareResponse[[]struct {
+		Id string `json:"id"`
+	}]
+	err := p.client.Get(ctx, "/zones", map[string]string{"name": domain}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if err = resp.err(); err != nil {
+		return "", err
+	}
+	if len(resp.Result) == 0 {
+		return "", errutil.WrapNotFound("cloudflare zone " + domain)
+	}
+	p.zones[domain] = resp.Result[0].Id
+	return resp.Result[0].Id, nil
+}
+
+func (p *Cloudflare) GetRecord(ctx context.Context, key Record) (Record, error) {
+	zone, err := p.zoneId(ctx, key.Domain)
+	if err != nil {
+		return Record{}, err
+	}
+	r := Record{Domain: key.Domain, Name: key.Name, Type: key.Type}
+	var resp cloudflareResponse[[]cloudflareRecord]
+	err = p.client.Get(ctx, "/zones/"+url.PathEscape(zone)+"/dns_records",
+		map[string]string{"type": r.Type, "name": r.Fqdn()}, &resp)
+	if err != nil {
+		return Record{}, err
+	}
+	if err = resp.err(); err != nil {
+		return Record{}, err
+	}
+	if len(resp.Result) == 0 {
+		return Record{}, notFound(r)
+	}
+	r.Id = resp.Result[0].Id
+	r.Value = resp.Result[0].Content
+	r.TTL = resp.Result[0].TTL
+	return r, nil
+}
+
+func (p *Cloudflare) UpsertRecord(ctx context.Context, r Record) (Record, error) {
+	return upsert(ctx, p, r, p.create, p.modify)
+}
+
+func (p *Cloudflare) create(ctx context.Context, r Record) (Record, error) {
+	return p.write(ctx, http.MethodPost, "", r)
+}
+
+func (p *Cloudflare) modify(ctx context.Context, r Record) (Record, error) {
+	res, err := p.write(ctx, http.MethodPut, "/"+url.PathEscape(r.Id), r)
+	if httpclient.IsStatus(err, http.StatusNotFound) {
+		return Record{}, errutil.WrapNotFound(r.Type + " record " + r.Id)
+	}
+	return res, err
+}
+
+func (p *Cloudflare) write(ctx context.Context, method, suffix string, r Record) (Record, error) {
+	zone, err := p.zoneId(ctx, r.Domain)
+	if err != nil {
+		return Record{}, err
+	}
+	ttl := r.TTL
+	if ttl == 0 {
+		// 1 表示自动
+		ttl = 1
+	}
+	body := cloudflareRecord{Type: r.Type, Name: r.Fqdn(), Content: r.Value, TTL: ttl, Proxied: p.proxied}
+	var resp cloudflareResponse[cloudflareRecord]
+	err = p.client.Do(ctx, method, "/zones/"+url.PathEscape(zone)+"/dns_records"+suffix, body, &resp)
+	if err != nil {
+		return Record{}, err
+	}
+	if err = resp.err(); err != nil {
+		return Record{}, err
+	}
+	r.Id = resp.Result.Id
+	return r, nil
+}
+
+func (p *Cloudflare) DeleteRecord(ctx context.Context, r Record) error {
+	id, err := lookupId(ctx, p, r)
+	if err != nil || id == "" {
+		return err
+	}
+	zone, err := p.zoneId(ctx, r.Domain)
+	if err != nil {
+		return err
+	}
+	var resp cloudflareResponse[struct{}]
+	err = p.client.Do(ctx, http.MethodDelete, "/zones/"+url.PathEscape(zone)+"/dns_records/"+url.PathEscape(id), nil, &resp)
+	if httpclient.IsStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.err()
+}
@@ -0,0 +1,93 @@
+package ddns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCloudflareServer 模拟 Cloudflare API v4 的 zone 与 dns_records 接口
+func newCloudflareServer(t *testing.T, zone *fakeZone, domain string) *httptest.Server {
+	reply := func(w http.ResponseWriter, result any) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": result})
+	}
+	toRecord := func(r *http.Request) Record {
+		var body cloudflareRecord
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		return Record{Name: body.Name, Type: body.Type, Value: body.Content, TTL: body.TTL}
+	}
+	toResult := func(r Record) cloudflareRecord {
+		return cloudflareRecord{Id: r.Id, Name: r.Name, Type: r.Type, Content: r.Value, TTL: r.TTL}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /zones", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != domain {
+			reply(w, []any{})
+			return
+		}
+		reply(w, []map[string]string{{"id": "zone1"}})
+	})
+	mux.HandleFunc("GET /zones/zone1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		cur, ok := zone.get(r.URL.Query().Get("name"), r.URL.Query().Get("type"))
+		if !ok {
+			reply(w, []any{})
+			return
+		}
+		reply(w, []cloudflareRecord{toResult(cur)})
+	})
+	mux.HandleFunc("POST /zones/zone1/dns_records", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, toResult(zone.create(toRecord(r))))
+	})
+	mux.HandleFunc("PUT /zones/zone1/dns_records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rec := toRecord(r)
+		if !zone.modify(r.PathValue("id"), rec) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rec.Id = r.PathValue("id")
+		reply(w, toResult(rec))
+	})
+	mux.HandleFunc("DELETE /zones/zone1/dns_records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !zone.delete(r.PathValue("id")) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(w, map[string]string{"id": r.PathValue("id")})
+	})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]any{"success": false,
+				"errors": []map[string]any{{"code": 9109, "message": "Invalid access token"}}})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCloudflare(t *testing.T) {
+	zone := newFakeZone()
+	s := newCloudflareServer(t, zone, "example.com")
+	p, err := NewCloudflare(CloudflareConfig{ApiToken: "token", BaseUrl: s.URL})
+	require.NoError(t, err)
+	testProvider(t, p, "example.com", "home")
+
+	// Cloudflare 使用完整域名作为记录名
+	_, ok := zone.get("home.example.com", TypeAAAA)
+	assert.True(t, ok)
+
+	p, err = NewCloudflare(CloudflareConfig{ApiToken: "bad", BaseUrl: s.URL})
+	require.NoError(t, err)
+	_, err = p.GetRecord(t.Context(), Record{Domain: "example.com", Name: "home", Type: TypeA})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "Invalid access token"), err.Error())
+}
@@ -0,0 +1,172 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	sdkerrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	dnspod "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/dnspod/v20210323"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const defaultDnspodRecordLine = "默认"
+
+type DnspodConfig struct {
+	SecretId  string `mapstructure:"secret_id" validate:"required"`
+	SecretKey string `mapstructure:"secret_key" validate:"required"`
+	// RecordLine 为线路，默认为 "默认"
+	RecordLine string `mapstructure:"record_line"`
+	// Endpoint 默认为 dnspod.tencentcloudapi.com，Scheme 默认为 HTTPS，用于测试
+	Endpoint string `mapstructure:"endpoint"`
+	Scheme   string `mapstructure:"scheme"`
+}
+
+// Dnspod 为腾讯云 DNSPod
+type Dnspod struct {
+	client *dnspod.Client
+	line   string
+}
+
+func NewDnspod(c DnspodConfig) (*Dnspod, error) {
+	cpf := profile.NewClientProfile()
+	cpf.HttpProfile.Endpoint = "dnspod.tencentcloudapi.com"
+	if c.Endpoint != "" {
+		cpf.HttpProfile.Endpoint = c.Endpoint
+	}
+	if c.Scheme != "" {
+		cpf.HttpProfile.Scheme = c.Scheme
+	}
+	client, err := dnspod.NewClient(common.NewCredential(c.SecretId, c.SecretKey), "", cpf)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	line := c.RecordLine
+	if line == "" {
+		line = defaultDnspodRecordLine
+	}
+	return &Dnspod{client: client, line: line}, nil
+}
+
+func (p *Dnspod) GetRecord(ctx context.Context, key Record) (Record, error) {
+	r := Record{Domain: key.Domain, Name: subName(key.Name), Type: key.Type, Line: p.recordLine(key)}
+	req := dnspod.NewDescribeRecordListRequest()
+	req.SetContext(ctx)
+	req.Domain = common.StringPtr(r.Domain)
+	req.Subdomain = common.StringPtr(r.Name)
+	req.RecordType = common.StringPtr(r.Type)
+	req.RecordLine = common.StringPtr(r.Line)
+	resp, err := p.client.DescribeRecordList(req)
+	var sdkErr *sdkerrors.TencentCloudSDKError
+	if errors.As(err, &sdkErr) && sdkErr.GetCode() == dnspod.RESOURCENOTFOUND_NODATAOFRECORD {
+		return Record{}, notFound(r)
+	}
+	if err != nil {
+		return Record{}, errutil.Wrap(err)
+	}
+	for _, item := range resp.Response.RecordList {
+		if item == nil || item.RecordId == nil {
+			continue
+		}
+		r.Id = strconv.FormatUint(*item.RecordId, 10)
+		if item.Value != nil {
+			r.Value = *item.Value
+		}
+		if item.TTL != nil {
+			r.TTL = int(*item.TTL)
+		}
+		return r, nil
+	}
+	return Record{}, notFound(r)
+}
+
+func (p *Dnspod) UpsertRecord(ctx context.Context, r Record) (Record, error) {
+	r.Name = subName(r.Name)
+	return upsert(ctx, p, r, p.create, p.modify)
+}
+
+func (p *Dnspod) create(ctx context.Context, r Record) (Record, error) {
+	req := dnspod.NewCreateRecordRequest()
+	req.SetContext(ctx)
+	req.Domain = common.StringPtr(r.Domain)
+	req.SubDomain = common.StringPtr(r.Name)
+	req.RecordType = common.StringPtr(r.Type)
+	req.RecordLine = common.StringPtr(p.recordLine(r))
+	req.Value = common.StringPtr(r.Value)
+	if r.TTL > 0 {
+		req.TTL = common.Uint64Ptr(uint64(r.TTL))
+	}
+	resp, err := p.client.CreateRecord(req)
+	if err != nil {
+		return Record{}, errutil.Wrap(err)
+	}
+	if resp.Response.RecordId != nil {
+		r.Id = strconv.FormatUint(*resp.Response.RecordId, 10)
+	}
+	return r, nil
+}
+
+func (p *Dnspod) modify(ctx context.Context, r Record) (Record, error) {
+	id, err := strconv.ParseUint(r.Id, 10, 64)
+	if err != nil {
+		return Record{}, errutil.WrapF("invalid dnspod record id %q: %w", r.Id, err)
+	}
+	req := dnspod.NewModifyRecordRequest()
+	req.SetContext(ctx)
+	req.Domain = common.StringPtr(r.Domain)
+	req.RecordId = common.Uint64Ptr(id)
+	req.SubDomain = common.StringPtr(r.Name)
+	req.RecordType = common.StringPtr(r.Type)
+	req.RecordLine = common.StringPtr(p.recordLine(r))
+	req.Value = common.StringPtr(r.Value)
+	if r.TTL > 0 {
+		req.TTL = common.Uint64Ptr(uint64(r.TTL))
+	}
+	_, err = p.client.ModifyRecord(req)
+	var sdkErr *sdkerrors.TencentCloudSDKError
+	if errors.As(err, &sdkErr) && sdkErr.GetCode() == dnspod.INVALIDPARAMETER_RECORDIDINVALID {
+		return Record{}, errutil.WrapNotFound(r.Type + " record " + r.Id)
+	}
+	if err != nil {
+		return Record{}, errutil.Wrap(err)
+	}
+	return r, nil
+}
+
+func (p *Dnspod) DeleteRecord(ctx context.Context, r Record) error {
+	r.Name = subName(r.Name)
+	idStr, err := lookupId(ctx, p, r)
+	if err != nil || idStr == "" {
+		return err
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return errutil.WrapF("invalid dnspod record id %q: %w", idStr, err)
+	}
+	req := dnspod.NewDeleteRecordRequest()
+	req.SetContext(ctx)
+	req.Domain = common.StringPtr(r.Domain)
+	req.RecordId = common.Uint64Ptr(id)
+	_, err = p.client.DeleteRecord(req)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+func (p *Dnspod) recordLine(r Record) string {
+	if r.Line != "" {
+		return r.Line
+	}
+	return p.line
+}
+
+// subName 将空主机记录统一为 "@"
+func subName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
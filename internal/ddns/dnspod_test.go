@@ -0,0 +1,83 @@
+package ddns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newDnspodServer 模拟 DNSPod API 3.0 的记录接口
+func newDnspodServer(t *testing.T, zone *fakeZone) *httptest.Server {
+	reply := func(w http.ResponseWriter, resp map[string]any) {
+		resp["RequestId"] = "req"
+		_ = json.NewEncoder(w).Encode(map[string]any{"Response": resp})
+	}
+	fail := func(w http.ResponseWriter, code string) {
+		reply(w, map[string]any{"Error": map[string]string{"Code": code, "Message": code}})
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=id/") {
+			fail(w, "AuthFailure.SignatureFailure")
+			return
+		}
+		var req struct {
+			SubDomain  string
+			Subdomain  string
+			RecordType string
+			Value      string
+			RecordId   uint64
+			TTL        int
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		id := strconv.FormatUint(req.RecordId, 10)
+		switch r.Header.Get("X-TC-Action") {
+		case "DescribeRecordList":
+			cur, ok := zone.get(req.Subdomain, req.RecordType)
+			if !ok {
+				fail(w, "ResourceNotFound.NoDataOfRecord")
+				return
+			}
+			curId, _ := strconv.ParseUint(cur.Id, 10, 64)
+			reply(w, map[string]any{"RecordList": []map[string]any{
+				{"RecordId": curId, "Name": cur.Name, "Type": cur.Type, "Value": cur.Value, "TTL": cur.TTL},
+			}})
+		case "CreateRecord":
+			created := zone.create(Record{Name: req.SubDomain, Type: req.RecordType, Value: req.Value, TTL: req.TTL})
+			createdId, _ := strconv.ParseUint(created.Id, 10, 64)
+			reply(w, map[string]any{"RecordId": createdId})
+		case "ModifyRecord":
+			if !zone.modify(id, Record{Name: req.SubDomain, Type: req.RecordType, Value: req.Value, TTL: req.TTL}) {
+				fail(w, "InvalidParameter.RecordIdInvalid")
+				return
+			}
+			reply(w, map[string]any{"RecordId": req.RecordId})
+		case "DeleteRecord":
+			if !zone.delete(id) {
+				fail(w, "InvalidParameter.RecordIdInvalid")
+				return
+			}
+			reply(w, map[string]any{})
+		default:
+			fail(w, "UnsupportedOperation")
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestDnspod(t *testing.T) {
+	s := newDnspodServer(t, newFakeZone())
+	p, err := NewDnspod(DnspodConfig{
+		SecretId:  "id",
+		SecretKey: "key",
+		Endpoint:  strings.TrimPrefix(s.URL, "http://"),
+		Scheme:    "HTTP",
+	})
+	require.NoError(t, err)
+	testProvider(t, p, "example.com", "home")
+}
@@ -0,0 +1,162 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// Family 为 IP 地址族
+type Family string
+
+const (
+	FamilyIpv4 Family = "ipv4"
+	FamilyIpv6 Family = "ipv6"
+)
+
+// RecordType 返回地址族对应的记录类型
+func (f Family) RecordType() string {
+	if f == FamilyIpv6 {
+		return TypeAAAA
+	}
+	return TypeA
+}
+
+func (f Family) network() string {
+	if f == FamilyIpv6 {
+		return "tcp6"
+	}
+	return "tcp4"
+}
+
+func (f Family) match(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if f == FamilyIpv6 {
+		return addr.Is6()
+	}
+	return addr.Is4()
+}
+
+// transport 返回只通过 family 建立连接的 http.Transport
+func (f Family) transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, f.network(), addr)
+	}
+	return transport
+}
+
+// GetMyIpFamily 只通过 family 对应的网络连接 endpoint，获取该地址族的公网 IP
+func GetMyIpFamily(ctx context.Context, endpoint string, family Family) (string, error) {
+	return getMyIpAuth(ctx, endpoint, family, Auth{})
+}
+
+func getMyIpAuth(ctx context.Context, endpoint string, family Family, auth Auth) (string, error) {
+	var data MyIpResponse
+	c := httpclient.New(httpclient.Config{BaseUrl: endpoint, Transport: auth.Transport(endpoint, family.transport())})
+	err := c.Do(ctx, http.MethodGet, "/my_ip", nil, &data)
+	if err != nil {
+		return "", err
+	}
+	addr, err := netip.ParseAddr(data.Ip)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	// 经过代理时服务端看到的地址族可能不同
+	if !family.match(addr) {
+		return "", errutil.WrapF("got %s from %s, want %s", data.Ip, endpoint, family)
+	}
+	return addr.Unmap().String(), nil
+}
+
+// InterfaceIps 返回网卡上 family 的全局单播地址，包括私有地址 (如 IPv6 ULA)，由 Detector 按配置过滤。
+// name 为空时查找所有网卡，公网地址在前，同类地址按字典序排列
+func InterfaceIps(name string, family Family) ([]netip.Addr, error) {
+	var ifaces []net.Interface
+	if name != "" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, errutil.Wrap(err)
+		}
+		ifaces = append(ifaces, *iface)
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, errutil.Wrap(err)
+		}
+		ifaces = all
+	}
+
+	var ips []netip.Addr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, errutil.Wrap(err)
+		}
+		for _, a := range addrs {
+			prefix, err := netip.ParsePrefix(a.String())
+			if err != nil {
+				continue
+			}
+			if addr := prefix.Addr().Unmap(); family.match(addr) && addr.IsGlobalUnicast() {
+				ips = append(ips, addr)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return nil, errutil.WrapNotFound(string(family) + " global address on interface " + name)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if pi, pj := isPublic(ips[i]), isPublic(ips[j]); pi != pj {
+			return pi
+		}
+		return ips[i].Less(ips[j])
+	})
+	return ips, nil
+}
+
+// bogons 为不会出现在公网上的保留地址段，见 RFC 6890
+var bogons = func() []netip.Prefix {
+	var ps []netip.Prefix
+	for _, s := range []string{
+		"0.0.0.0/8",
+		"100.64.0.0/10",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"240.0.0.0/4",
+		"64:ff9b:1::/48",
+		"100::/64",
+		"2001:2::/48",
+		"2001:db8::/32",
+	} {
+		ps = append(ps, netip.MustParsePrefix(s))
+	}
+	return ps
+}()
+
+// isPublic 判断 addr 是否为公网地址，排除私有、运营商级 NAT (100.64.0.0/10) 与文档等保留地址
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range bogons {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
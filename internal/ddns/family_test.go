@@ -0,0 +1,83 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMyIpServer(t *testing.T, network, addr string) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	LoadRouter(&e.RouterGroup)
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Skipf("listen %s %s: %v", network, addr, err)
+	}
+	s := httptest.NewUnstartedServer(e)
+	_ = s.Listener.Close()
+	s.Listener = l
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGetMyIpFamily(t *testing.T) {
+	s := newMyIpServer(t, "tcp4", "127.0.0.1:0")
+	ip, err := GetMyIpFamily(context.Background(), s.URL, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip)
+
+	// 只通过 IPv6 连接时无法访问 IPv4 地址
+	_, err = GetMyIpFamily(context.Background(), s.URL, FamilyIpv6)
+	assert.Error(t, err)
+
+	s6 := newMyIpServer(t, "tcp6", "[::1]:0")
+	ip, err = GetMyIpFamily(context.Background(), s6.URL, FamilyIpv6)
+	require.NoError(t, err)
+	assert.Equal(t, "::1", ip)
+}
+
+func TestMyIpUnmap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	LoadRouter(&e.RouterGroup)
+	req := httptest.NewRequest(http.MethodGet, "/my_ip", nil)
+	req.RemoteAddr = "[::ffff:1.2.3.4]:5000"
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	var data MyIpResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, "1.2.3.4", data.Ip)
+	assert.Equal(t, 5000, data.Port)
+}
+
+func TestIsPublic(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"1.1.1.1":         true,
+		"192.168.1.1":     false,
+		"2001:db8::1":     false,
+		"240e:1::1":       true,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::1":             false,
+		"100.64.0.1":      false,
+		"100.128.0.1":     true,
+		"198.51.100.7":    false,
+		"0.1.2.3":         false,
+		"255.255.255.255": false,
+		"::ffff:8.8.8.8":  true,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, want, isPublic(netip.MustParseAddr(ip)), ip)
+	}
+}
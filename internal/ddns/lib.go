@@ -1,33 +1,24 @@
 package ddns
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/go-resty/resty/v2"
-	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"context"
 	"net/http"
-	"net/url"
-)
+	"net/netip"
 
-func GetMyIp(endpoint string) (string, error) {
-	u, err := url.JoinPath(endpoint, "/my_ip")
-	if err != nil {
-		return "", errutil.Wrap(err)
-	}
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
 
-	resp, err := resty.New().R().Get(u)
+// GetMyIp 通过 endpoint 获取本机的公网 IP，响应中没有合法的 IP 时返回错误
+func GetMyIp(ctx context.Context, endpoint string) (string, error) {
+	var data MyIpResponse
+	err := httpclient.New(httpclient.Config{BaseUrl: endpoint}).Do(ctx, http.MethodGet, "/my_ip", nil, &data)
 	if err != nil {
-		return "", errutil.Wrap(err)
-	}
-
-	if resp.StatusCode() != http.StatusOK {
-		return "", fmt.Errorf("request failed: code=%d, body=%s", resp.StatusCode(), string(resp.Body()))
+		return "", err
 	}
-
-	var data MyIpResponse
-	err = json.Unmarshal(resp.Body(), &data)
+	addr, err := netip.ParseAddr(data.Ip)
 	if err != nil {
-		return "", errutil.Wrap(err)
+		return "", errutil.WrapF("invalid ip %q from %s: %w", data.Ip, endpoint, err)
 	}
-	return data.Ip, nil
+	return addr.Unmap().String(), nil
 }
@@ -1,13 +1,29 @@
 package ddns
 
 import (
+	"context"
 	"fmt"
-	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetMyIp(t *testing.T) {
-	ip, err := GetMyIp("http://127.0.0.1:5801")
+	ip, err := GetMyIp(context.Background(), "http://127.0.0.1:5801")
 	assert.Nil(t, err)
 	fmt.Println(ip)
 }
+
+func TestGetMyIpInvalid(t *testing.T) {
+	for _, body := range []string{`{}`, `{"code":0,"data":{"ip":"1.2.3.4"}}`, `{"ip":"x"}`} {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}))
+		_, err := GetMyIp(context.Background(), s.URL)
+		assert.Error(t, err, body)
+		s.Close()
+	}
+}
@@ -0,0 +1,83 @@
+package ddns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const (
+	natpmpPort = "5351"
+	// natpmpMaxAttempts 为 RFC 6886 建议的最大发送次数
+	natpmpMaxAttempts = 9
+)
+
+type natpmpSource struct {
+	// gateway 为网关地址，可带端口
+	gateway string
+}
+
+func (s *natpmpSource) Name() string {
+	return SourceNatpmp + " " + s.gateway
+}
+
+// GetIp 发送外部地址请求，按 RFC 6886 从 250ms 起倍增重传，直到收到响应或 ctx 结束
+func (s *natpmpSource) GetIp(ctx context.Context, family Family) (netip.Addr, error) {
+	if family != FamilyIpv4 {
+		return netip.Addr{}, errutil.WrapF("natpmp only supports ipv4")
+	}
+	addr := s.gateway
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, natpmpPort)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", addr)
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 16)
+	wait := 250 * time.Millisecond
+	for i := 0; i < natpmpMaxAttempts; i, wait = i+1, wait*2 {
+		if _, err = conn.Write([]byte{0, 0}); err != nil {
+			return netip.Addr{}, errutil.Wrap(err)
+		}
+		deadline := time.Now().Add(wait)
+		ctxDeadline, ok := ctx.Deadline()
+		last := ok && !ctxDeadline.After(deadline)
+		if last {
+			deadline = ctxDeadline
+		}
+		_ = conn.SetReadDeadline(deadline)
+		n, err := conn.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if last {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return netip.Addr{}, errutil.Wrap(err)
+		}
+		return parseNatpmp(buf[:n])
+	}
+	return netip.Addr{}, errutil.WrapF("natpmp request to %s timed out", addr)
+}
+
+// parseNatpmp 解析外部地址响应：版本、操作码 128、结果码、时间戳与 IPv4 地址
+func parseNatpmp(b []byte) (netip.Addr, error) {
+	if len(b) < 12 || b[0] != 0 || b[1] != 128 {
+		return netip.Addr{}, errutil.WrapF("invalid natpmp response % x", b)
+	}
+	if code := binary.BigEndian.Uint16(b[2:4]); code != 0 {
+		return netip.Addr{}, errutil.WrapF("natpmp request failed with result code %d", code)
+	}
+	return netip.AddrFrom4([4]byte(b[8:12])), nil
+}
@@ -0,0 +1,90 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+)
+
+// 通知的事件类型
+const (
+	// EventChanged 为记录值变化
+	EventChanged = "changed"
+	// EventFailed 为记录连续失败达到阈值
+	EventFailed = "failed"
+	// EventRecovered 为达到失败阈值后再次同步成功
+	EventRecovered = "recovered"
+)
+
+// Event 为发送给 Notifier 的事件
+type Event struct {
+	Type       string    `json:"type"`
+	Record     string    `json:"record"`
+	Fqdn       string    `json:"fqdn"`
+	RecordType string    `json:"record_type"`
+	Old        string    `json:"old,omitempty"`
+	New        string    `json:"new,omitempty"`
+	Err        string    `json:"err,omitempty"`
+	Failures   int       `json:"failures,omitempty"`
+	Time       time.Time `json:"time"`
+	// Text 为可读的描述，便于直接转发到聊天工具的 webhook
+	Text string `json:"text"`
+}
+
+func newEvent(typ string, t Target, st *Status, old string) Event {
+	e := Event{
+		Type:       typ,
+		Record:     t.Key(),
+		Fqdn:       Fqdn(t.Domain, t.Name),
+		RecordType: t.Type,
+		Old:        old,
+		New:        st.Value,
+		Err:        st.Err,
+		Failures:   st.Failures,
+		Time:       st.Checked,
+	}
+	switch typ {
+	case EventChanged:
+		e.Text = fmt.Sprintf("ddns: %s %s changed from %q to %q", e.RecordType, e.Fqdn, old, st.Value)
+	case EventFailed:
+		e.Text = fmt.Sprintf("ddns: %s %s failed %d times: %s", e.RecordType, e.Fqdn, st.Failures, st.Err)
+	case EventRecovered:
+		e.Text = fmt.Sprintf("ddns: %s %s recovered, value %q", e.RecordType, e.Fqdn, st.Value)
+	}
+	return e
+}
+
+// Notifier 发送记录变化与失败的通知
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Webhook 以 JSON POST Event 到 url
+type Webhook struct {
+	url    string
+	client *httpclient.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: httpclient.New(httpclient.Config{})}
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	return w.client.PostJSON(ctx, w.url, e, nil)
+}
+
+// Notifiers 将事件发送给所有 Notifier
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, n := range ns {
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	var lock sync.Mutex
+	var events []Event
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		lock.Lock()
+		events = append(events, e)
+		lock.Unlock()
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	p := newMemProvider()
+	syncer, err := NewSyncer(map[string]Provider{"": p},
+		[]Target{{Domain: "example.com", Name: "www", Type: TypeA, Create: true}})
+	require.NoError(t, err)
+	syncer.SetNotifier(Notifiers{NewWebhook(s.URL)}, 2)
+
+	require.NoError(t, syncer.Sync(ctx, map[Family]string{FamilyIpv4: "1.1.1.1"}))
+	p.fail["www.example.com"] = true
+	for range 3 {
+		assert.Error(t, syncer.Sync(ctx, map[Family]string{FamilyIpv4: "2.2.2.2"}))
+	}
+	p.fail["www.example.com"] = false
+	require.NoError(t, syncer.Sync(ctx, map[Family]string{FamilyIpv4: "2.2.2.2"}))
+
+	lock.Lock()
+	defer lock.Unlock()
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	// 连续失败只在达到阈值时通知一次
+	assert.Equal(t, []string{EventChanged, EventFailed, EventRecovered, EventChanged}, types)
+	assert.Equal(t, "www.example.com", events[0].Fqdn)
+	assert.Equal(t, "2.2.2.2", events[3].New)
+	assert.Equal(t, "1.1.1.1", events[3].Old)
+	assert.Equal(t, 2, events[1].Failures)
+	assert.Contains(t, events[1].Text, "failed 2 times")
+}
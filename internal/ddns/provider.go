@@ -0,0 +1,110 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const (
+	TypeA    = "A"
+	TypeAAAA = "AAAA"
+)
+
+// 支持的 DNS 服务商，对应配置 ddns.provider
+const (
+	ProviderDnspod     = "dnspod"
+	ProviderCloudflare = "cloudflare"
+	ProviderAlidns     = "alidns"
+	ProviderRfc2136    = "rfc2136"
+)
+
+// Record 为一条 DNS 记录
+type Record struct {
+	// Id 为服务商的记录 ID，为空时按 Domain、Name、Type 查找
+	Id     string
+	Domain string
+	// Name 为主机记录，"@" 表示根域名
+	Name  string
+	Type  string
+	Value string
+	// TTL 为 0 时使用服务商的默认值
+	TTL int
+	// Line 为线路，仅 DNSPod 支持，为空时使用服务商配置的默认线路
+	Line string
+}
+
+// Fqdn 返回完整域名，不带末尾的点
+func (r Record) Fqdn() string {
+	return Fqdn(r.Domain, r.Name)
+}
+
+// Provider 为 DNS 服务商
+type Provider interface {
+	// GetRecord 按 Domain、Name、Type (以及 Line) 查找记录，不存在时返回包装了 errutil.ErrNotFound 的错误
+	GetRecord(ctx context.Context, r Record) (Record, error)
+	// UpsertRecord 更新记录，Id 为空时先查找，不存在则创建，返回服务商中的记录
+	UpsertRecord(ctx context.Context, r Record) (Record, error)
+	// DeleteRecord 删除记录，记录不存在时不返回错误
+	DeleteRecord(ctx context.Context, r Record) error
+}
+
+func Fqdn(domain, name string) string {
+	domain = strings.TrimSuffix(domain, ".")
+	if name == "" || name == "@" {
+		return domain
+	}
+	return name + "." + domain
+}
+
+// RecordType 按 IP 地址族返回记录类型
+func RecordType(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	if addr.Unmap().Is4() {
+		return TypeA, nil
+	}
+	return TypeAAAA, nil
+}
+
+func notFound(r Record) error {
+	return errutil.WrapNotFound(r.Type + " record " + r.Fqdn())
+}
+
+// upsert 为各服务商共用的查找后更新或创建逻辑
+func upsert(ctx context.Context, p Provider, r Record,
+	create func(context.Context, Record) (Record, error),
+	modify func(context.Context, Record) (Record, error)) (Record, error) {
+	if r.Id != "" {
+		return modify(ctx, r)
+	}
+	cur, err := p.GetRecord(ctx, r)
+	if errors.Is(err, errutil.ErrNotFound) {
+		return create(ctx, r)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	r.Id = cur.Id
+	return modify(ctx, r)
+}
+
+// lookupId 在 Id 为空时查找记录 ID，记录不存在时返回空字符串
+func lookupId(ctx context.Context, p Provider, r Record) (string, error) {
+	if r.Id != "" {
+		return r.Id, nil
+	}
+	cur, err := p.GetRecord(ctx, r)
+	if errors.Is(err, errutil.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return cur.Id, nil
+}
@@ -0,0 +1,131 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// fakeZone 为各服务商替身共用的记录存储，key 为 "name type"
+type fakeZone struct {
+	lock    sync.Mutex
+	nextId  int
+	records map[string]*Record
+}
+
+func newFakeZone() *fakeZone {
+	return &fakeZone{nextId: 100, records: make(map[string]*Record)}
+}
+
+func (z *fakeZone) get(name, typ string) (Record, bool) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	r, ok := z.records[name+" "+typ]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+func (z *fakeZone) create(r Record) Record {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.nextId++
+	r.Id = strconv.Itoa(z.nextId)
+	z.records[r.Name+" "+r.Type] = &r
+	return r
+}
+
+func (z *fakeZone) modify(id string, r Record) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	for k, cur := range z.records {
+		if cur.Id == id {
+			delete(z.records, k)
+			r.Id = id
+			z.records[r.Name+" "+r.Type] = &r
+			return true
+		}
+	}
+	return false
+}
+
+func (z *fakeZone) delete(id string) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	for k, cur := range z.records {
+		if cur.Id == id {
+			delete(z.records, k)
+			return true
+		}
+	}
+	return false
+}
+
+// testProvider 验证 Provider 的通用行为
+func testProvider(t *testing.T, p Provider, domain, name string) {
+	ctx := context.Background()
+
+	_, err := p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA})
+	require.True(t, errors.Is(err, errutil.ErrNotFound), "%v", err)
+
+	created, err := p.UpsertRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA, Value: "1.1.1.1", TTL: 600})
+	require.NoError(t, err)
+
+	got, err := p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA})
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", got.Value)
+	assert.Equal(t, 600, got.TTL)
+	assert.Equal(t, created.Id, got.Id)
+
+	// 记录 ID 失效时返回 ErrNotFound，以便调用方重新查找
+	if created.Id != "" {
+		_, err = p.UpsertRecord(ctx, Record{Id: "999999", Domain: domain, Name: name, Type: TypeA, Value: "1.1.1.1"})
+		require.True(t, errors.Is(err, errutil.ErrNotFound), "%v", err)
+	}
+
+	// Id 为空时按名称查找后更新
+	_, err = p.UpsertRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA, Value: "2.2.2.2", TTL: 600})
+	require.NoError(t, err)
+	got, err = p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA})
+	require.NoError(t, err)
+	assert.Equal(t, "2.2.2.2", got.Value)
+	assert.Equal(t, created.Id, got.Id)
+
+	_, err = p.UpsertRecord(ctx, Record{Domain: domain, Name: name, Type: TypeAAAA, Value: "2001:db8::1", TTL: 600})
+	require.NoError(t, err)
+	got, err = p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeAAAA})
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", got.Value)
+
+	require.NoError(t, p.DeleteRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA}))
+	_, err = p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA})
+	require.True(t, errors.Is(err, errutil.ErrNotFound), "%v", err)
+	require.NoError(t, p.DeleteRecord(ctx, Record{Domain: domain, Name: name, Type: TypeA}))
+
+	_, err = p.GetRecord(ctx, Record{Domain: domain, Name: name, Type: TypeAAAA})
+	require.NoError(t, err)
+}
+
+func TestRecordType(t *testing.T) {
+	typ, err := RecordType("1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, TypeA, typ)
+	typ, err = RecordType("::ffff:1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, TypeA, typ)
+	typ, err = RecordType("2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, TypeAAAA, typ)
+	_, err = RecordType("x")
+	assert.Error(t, err)
+
+	assert.Equal(t, "example.com", Fqdn("example.com.", "@"))
+	assert.Equal(t, "www.example.com", Fqdn("example.com", "www"))
+}
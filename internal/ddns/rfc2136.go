@@ -0,0 +1,154 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const defaultRfc2136TTL = 300
+
+type Rfc2136Config struct {
+	// Server 为接受动态更新的权威服务器，如 "ns1.example.com:53"
+	Server string `mapstructure:"server" validate:"required,hostname_port"`
+	// Zone 为空时使用记录的 Domain
+	Zone string `mapstructure:"zone"`
+	// Net 为 udp 或 tcp，默认 udp
+	Net string `mapstructure:"net" validate:"omitempty,oneof=udp tcp"`
+	// TsigName 与 TsigSecret (base64) 为空时不签名
+	TsigName      string        `mapstructure:"tsig_name"`
+	TsigSecret    string        `mapstructure:"tsig_secret" validate:"required_with=TsigName"`
+	TsigAlgorithm string        `mapstructure:"tsig_algorithm" validate:"omitempty,oneof=hmac-sha1 hmac-sha256 hmac-sha512"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// Rfc2136 通过 DNS UPDATE (RFC 2136) 修改记录，记录没有 ID
+type Rfc2136 struct {
+	c      Rfc2136Config
+	client *dns.Client
+}
+
+func NewRfc2136(c Rfc2136Config) (*Rfc2136, error) {
+	if _, _, err := net.SplitHostPort(c.Server); err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	if c.TsigAlgorithm == "" {
+		c.TsigAlgorithm = "hmac-sha256"
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+	client := &dns.Client{Net: c.Net, Timeout: c.Timeout}
+	if c.TsigName != "" {
+		client.TsigSecret = map[string]string{dns.Fqdn(c.TsigName): c.TsigSecret}
+	}
+	return &Rfc2136{c: c, client: client}, nil
+}
+
+func (p *Rfc2136) GetRecord(ctx context.Context, key Record) (Record, error) {
+	r := Record{Domain: key.Domain, Name: key.Name, Type: key.Type}
+	qtype, err := dnsType(r.Type)
+	if err != nil {
+		return Record{}, err
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(r.Fqdn()), qtype)
+	m.RecursionDesired = false
+	resp, err := p.exchange(ctx, m)
+	if err != nil {
+		return Record{}, err
+	}
+	if resp.Rcode == dns.RcodeNameError {
+		return Record{}, notFound(r)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return Record{}, errutil.WrapF("query %s failed: %s", r.Fqdn(), dns.RcodeToString[resp.Rcode])
+	}
+	for _, rr := range resp.Answer {
+		switch v := rr.(type) {
+		case *dns.A:
+			r.Value = v.A.String()
+		case *dns.AAAA:
+			r.Value = v.AAAA.String()
+		default:
+			continue
+		}
+		r.TTL = int(rr.Header().Ttl)
+		return r, nil
+	}
+	return Record{}, notFound(r)
+}
+
+// UpsertRecord 在一次更新中删除同名同类型的记录集并插入新记录
+func (p *Rfc2136) UpsertRecord(ctx context.Context, r Record) (Record, error) {
+	if r.TTL == 0 {
+		r.TTL = defaultRfc2136TTL
+	}
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(r.Fqdn()), r.TTL, r.Type, r.Value))
+	if err != nil {
+		return Record{}, errutil.Wrap(err)
+	}
+	m := p.update(r)
+	m.RemoveRRset([]dns.RR{rr})
+	m.Insert([]dns.RR{rr})
+	err = p.send(ctx, m)
+	if err != nil {
+		return Record{}, err
+	}
+	return r, nil
+}
+
+func (p *Rfc2136) DeleteRecord(ctx context.Context, r Record) error {
+	qtype, err := dnsType(r.Type)
+	if err != nil {
+		return err
+	}
+	rr := &dns.ANY{Hdr: dns.RR_Header{Name: dns.Fqdn(r.Fqdn()), Rrtype: qtype, Class: dns.ClassINET}}
+	m := p.update(r)
+	m.RemoveRRset([]dns.RR{rr})
+	return p.send(ctx, m)
+}
+
+func (p *Rfc2136) update(r Record) *dns.Msg {
+	zone := p.c.Zone
+	if zone == "" {
+		zone = r.Domain
+	}
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone))
+	return m
+}
+
+func (p *Rfc2136) send(ctx context.Context, m *dns.Msg) error {
+	resp, err := p.exchange(ctx, m)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return errutil.WrapF("dns update failed: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+func (p *Rfc2136) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	if p.c.TsigName != "" {
+		m.SetTsig(dns.Fqdn(p.c.TsigName), dns.Fqdn(p.c.TsigAlgorithm), 300, time.Now().Unix())
+	}
+	resp, _, err := p.client.ExchangeContext(ctx, m, p.c.Server)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	return resp, nil
+}
+
+func dnsType(typ string) (uint16, error) {
+	t, ok := dns.StringToType[typ]
+	if !ok {
+		return 0, errutil.WrapF("unknown record type %q", typ)
+	}
+	return t, nil
+}
@@ -0,0 +1,88 @@
+package ddns
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+
+// newDnsServer 启动接受 TSIG 签名更新的权威 DNS 替身
+func newDnsServer(t *testing.T, zone *fakeZone, origin string) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	name := func(fqdn string) string {
+		n := strings.TrimSuffix(strings.TrimSuffix(fqdn, dns.Fqdn(origin)), ".")
+		if n == "" {
+			return "@"
+		}
+		return n
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		defer func() {
+			if req.IsTsig() != nil {
+				m.SetTsig(req.IsTsig().Hdr.Name, dns.HmacSHA256, 300, int64(req.IsTsig().TimeSigned))
+			}
+			_ = w.WriteMsg(m)
+		}()
+
+		if req.Opcode == dns.OpcodeQuery {
+			q := req.Question[0]
+			cur, ok := zone.get(name(q.Name), dns.TypeToString[q.Qtype])
+			if !ok {
+				m.Rcode = dns.RcodeNameError
+				return
+			}
+			rr, _ := dns.NewRR(q.Name + " " + strconv.Itoa(cur.TTL) + " IN " + cur.Type + " " + cur.Value)
+			m.Answer = append(m.Answer, rr)
+			return
+		}
+
+		if req.IsTsig() == nil || w.TsigStatus() != nil {
+			m.Rcode = dns.RcodeRefused
+			return
+		}
+		for _, rr := range req.Ns {
+			h := rr.Header()
+			typ := dns.TypeToString[h.Rrtype]
+			if cur, ok := zone.get(name(h.Name), typ); ok && h.Class == dns.ClassANY {
+				zone.delete(cur.Id)
+			}
+			if h.Class == dns.ClassINET {
+				value := strings.TrimPrefix(rr.String(), h.String())
+				zone.create(Record{Name: name(h.Name), Type: typ, Value: value, TTL: int(h.Ttl)})
+			}
+		}
+	})
+
+	s := &dns.Server{PacketConn: pc, Handler: handler,
+		TsigSecret: map[string]string{"ddns.": testTsigSecret},
+		// 默认只接受查询
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept }}
+	go func() { _ = s.ActivateAndServe() }()
+	t.Cleanup(func() { _ = s.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestRfc2136(t *testing.T) {
+	zone := newFakeZone()
+	addr := newDnsServer(t, zone, "example.com")
+	p, err := NewRfc2136(Rfc2136Config{Server: addr, TsigName: "ddns", TsigSecret: testTsigSecret})
+	require.NoError(t, err)
+	testProvider(t, p, "example.com", "home")
+
+	// 未签名的更新被拒绝
+	p, err = NewRfc2136(Rfc2136Config{Server: addr})
+	require.NoError(t, err)
+	_, err = p.UpsertRecord(t.Context(), Record{Domain: "example.com", Name: "home", Type: TypeA, Value: "1.1.1.1"})
+	assert.ErrorContains(t, err, "REFUSED")
+}
@@ -1,8 +1,14 @@
 package ddns
 
 import (
-	"github.com/gin-gonic/gin"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRouter(t *testing.T) {
@@ -11,3 +17,54 @@ func TestRouter(t *testing.T) {
 	LoadRouter(g)
 	_ = e.Run("127.0.0.1:5801")
 }
+
+func newTestRouter(t *testing.T, trusted ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	require.NoError(t, e.SetTrustedProxies(trusted))
+	LoadRouter(&e.RouterGroup)
+	return e
+}
+
+func serve(e *gin.Engine, path, remote string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remote
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestMyIpTrustedProxy(t *testing.T) {
+	e := newTestRouter(t, "10.0.0.0/8")
+	xff := map[string]string{"X-Forwarded-For": "9.9.9.9, 1.2.3.4, 10.0.0.2"}
+
+	var data MyIpResponse
+	w := serve(e, "/my_ip", "10.0.0.1:5000", xff)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, MyIpResponse{Ip: "1.2.3.4", Family: FamilyIpv4}, data)
+
+	w = serve(e, "/my_ip/text", "10.0.0.1:5000", map[string]string{"X-Real-IP": "2001:db8::1"})
+	assert.Equal(t, "2001:db8::1\n", w.Body.String())
+
+	// 不可信的来源不读取请求头
+	w = serve(e, "/my_ip", "[::ffff:8.8.8.8]:5000", xff)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, MyIpResponse{Ip: "8.8.8.8", Port: 5000, Family: FamilyIpv4}, data)
+
+	e = newTestRouter(t)
+	w = serve(e, "/my_ip/text", "10.0.0.1:5000", xff)
+	assert.Equal(t, "10.0.0.1\n", w.Body.String())
+}
+
+func TestMyIpFamily(t *testing.T) {
+	e := newTestRouter(t)
+	w := serve(e, "/my_ip/ipv4", "1.2.3.4:5000", nil)
+	assert.Equal(t, "1.2.3.4\n", w.Body.String())
+	w = serve(e, "/my_ip/ipv6", "1.2.3.4:5000", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(e, "/my_ip/ipv6", "[2001:db8::1]:5000", nil)
+	assert.Equal(t, "2001:db8::1\n", w.Body.String())
+}
@@ -1,26 +1,84 @@
 package ddns
 
 import (
-	"github.com/gin-gonic/gin"
+	"errors"
 	"net/http"
 	"net/netip"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
 )
 
+// LoadRouter 注册获取调用方地址的接口。经过代理时，调用方地址由 gin 的
+// ClientIP 按 Engine 的可信代理配置从 X-Forwarded-For 或 X-Real-IP 中获取
 func LoadRouter(g *gin.RouterGroup) {
 	g.GET("/my_ip", getMyIp)
+	g.GET("/my_ip/text", getMyIpText)
+	g.GET("/my_ip/ipv4", getMyIpFamily(FamilyIpv4))
+	g.GET("/my_ip/ipv6", getMyIpFamily(FamilyIpv6))
 }
 
 type MyIpResponse struct {
-	Ip   string `json:"ip"`
-	Port int    `json:"port"`
+	Ip string `json:"ip"`
+	// Port 为调用方的端口，地址来自 X-Forwarded-For 等请求头时为 0
+	Port   int    `json:"port"`
+	Family Family `json:"family"`
 }
 
-func getMyIp(r *gin.Context) {
-	ap, err := netip.ParseAddrPort(r.Request.RemoteAddr)
+// clientAddr 返回调用方地址，IPv4 映射的 IPv6 地址转为 IPv4
+func clientAddr(c *gin.Context) (netip.AddrPort, error) {
+	addr, err := netip.ParseAddr(c.ClientIP())
 	if err != nil {
-		r.JSON(http.StatusInternalServerError, gin.H{"err": err.Error()})
-		r.Abort()
+		return netip.AddrPort{}, errutil.Wrap(err)
+	}
+	addr = addr.Unmap()
+	remote, err := netip.ParseAddrPort(c.Request.RemoteAddr)
+	if err != nil || remote.Addr().Unmap() != addr {
+		return netip.AddrPortFrom(addr, 0), nil
+	}
+	return netip.AddrPortFrom(addr, remote.Port()), nil
+}
+
+func familyOf(addr netip.Addr) Family {
+	if addr.Is4() {
+		return FamilyIpv4
+	}
+	return FamilyIpv6
+}
+
+func getMyIp(c *gin.Context) {
+	ap, err := clientAddr(c)
+	if err != nil {
+		apiutil.Fail(c, err)
 		return
 	}
-	r.JSON(http.StatusOK, MyIpResponse{Ip: ap.Addr().String(), Port: int(ap.Port())})
+	// 与旧版本的 monitor 兼容，不使用 apiutil.Response 信封
+	c.JSON(http.StatusOK, MyIpResponse{Ip: ap.Addr().String(), Port: int(ap.Port()), Family: familyOf(ap.Addr())})
+}
+
+// getMyIpText 以纯文本返回调用方地址，便于 curl 使用
+func getMyIpText(c *gin.Context) {
+	ap, err := clientAddr(c)
+	if err != nil {
+		apiutil.Fail(c, err)
+		return
+	}
+	c.String(http.StatusOK, "%s\n", ap.Addr())
+}
+
+// getMyIpFamily 以纯文本返回调用方地址，调用方不是通过 family 访问时返回 404
+func getMyIpFamily(family Family) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ap, err := clientAddr(c)
+		if err != nil {
+			apiutil.Fail(c, err)
+			return
+		}
+		if !family.match(ap.Addr()) {
+			apiutil.Fail(c, apiutil.ErrNotFound.Wrap(errors.New("caller is not connected over "+string(family))))
+			return
+		}
+		c.String(http.StatusOK, "%s\n", ap.Addr())
+	}
 }
@@ -0,0 +1,289 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// IP 来源的类型，对应配置 ddns.ipv4.sources 中的 type
+const (
+	// SourceEndpoint 为 ddns server 的 /my_ip 接口
+	SourceEndpoint = "endpoint"
+	// SourceHttp 为返回纯文本 IP 的接口，如 https://api.ipify.org
+	SourceHttp      = "http"
+	SourceInterface = "interface"
+	// SourceUpnp 通过 UPnP IGD 向路由器查询 WAN 口地址，仅支持 IPv4
+	SourceUpnp = "upnp"
+	// SourceNatpmp 通过 NAT-PMP 向网关查询外部地址，仅支持 IPv4
+	SourceNatpmp = "natpmp"
+)
+
+// 多个来源的合并方式
+const (
+	// StrategyFirst 依次尝试各来源，使用第一个有效结果
+	StrategyFirst = "first"
+	// StrategyQuorum 同时查询所有来源，使用至少 Quorum 个来源一致的结果
+	StrategyQuorum = "quorum"
+)
+
+const defaultSourceTimeout = 10 * time.Second
+
+// Source 为公网 IP 的一个来源
+type Source interface {
+	// Name 用于日志与错误信息
+	Name() string
+	GetIp(ctx context.Context, family Family) (netip.Addr, error)
+}
+
+// multiSource 为可能返回多个地址的来源，如网卡上的多个地址，Detector 使用其中第一个有效的地址
+type multiSource interface {
+	Source
+	GetIps(ctx context.Context, family Family) ([]netip.Addr, error)
+}
+
+// SourceConfig 为单个来源的配置
+type SourceConfig struct {
+	Type string `mapstructure:"type" validate:"required,oneof=endpoint http interface upnp natpmp"`
+	// Url 为 endpoint 的 ddns server 地址，或 http 的接口地址
+	Url string `mapstructure:"url" validate:"omitempty,url"`
+	// Interface 为 interface 读取的网卡，为空时查找所有网卡
+	Interface string `mapstructure:"interface"`
+	// Location 为 upnp 的设备描述地址，为空时通过 SSDP 发现
+	Location string `mapstructure:"location" validate:"omitempty,url"`
+	// Gateway 为 natpmp 的网关地址
+	Gateway string `mapstructure:"gateway" validate:"omitempty,ip|hostname_port"`
+	// Auth 为访问 endpoint 的认证，不从来源配置中读取
+	Auth Auth `mapstructure:"-"`
+}
+
+func NewSource(c SourceConfig) (Source, error) {
+	switch c.Type {
+	case SourceEndpoint, SourceHttp:
+		if c.Url == "" {
+			return nil, errutil.WrapF("%s ip source requires url", c.Type)
+		}
+		if c.Type == SourceEndpoint {
+			return &endpointSource{url: c.Url, auth: c.Auth}, nil
+		}
+		return &httpSource{url: c.Url}, nil
+	case SourceInterface:
+		return &interfaceSource{name: c.Interface}, nil
+	case SourceUpnp:
+		return &upnpSource{location: c.Location}, nil
+	case SourceNatpmp:
+		if c.Gateway == "" {
+			return nil, errutil.WrapF("natpmp ip source requires gateway")
+		}
+		return &natpmpSource{gateway: c.Gateway}, nil
+	default:
+		return nil, errutil.WrapF("unknown ip source %q", c.Type)
+	}
+}
+
+type endpointSource struct {
+	url  string
+	auth Auth
+}
+
+func (s *endpointSource) Name() string {
+	return SourceEndpoint + " " + s.url
+}
+
+func (s *endpointSource) GetIp(ctx context.Context, family Family) (netip.Addr, error) {
+	ip, err := getMyIpAuth(ctx, s.url, family, s.auth)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.ParseAddr(ip)
+}
+
+type httpSource struct {
+	url string
+}
+
+func (s *httpSource) Name() string {
+	return SourceHttp + " " + s.url
+}
+
+func (s *httpSource) GetIp(ctx context.Context, family Family) (netip.Addr, error) {
+	c := httpclient.New(httpclient.Config{Transport: family.transport(), RetryCount: -1})
+	resp, err := c.R(ctx).Get(s.url)
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+	if !resp.IsSuccess() {
+		return netip.Addr{}, errutil.WrapF("get %s failed: %s", s.url, resp.Status())
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(resp.String()))
+	if err != nil {
+		return netip.Addr{}, errutil.WrapF("invalid response from %s: %w", s.url, err)
+	}
+	return addr, nil
+}
+
+type interfaceSource struct {
+	name string
+}
+
+func (s *interfaceSource) Name() string {
+	return SourceInterface + " " + s.name
+}
+
+func (s *interfaceSource) GetIp(ctx context.Context, family Family) (netip.Addr, error) {
+	ips, err := s.GetIps(ctx, family)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return ips[0], nil
+}
+
+func (s *interfaceSource) GetIps(_ context.Context, family Family) ([]netip.Addr, error) {
+	return InterfaceIps(s.name, family)
+}
+
+// DetectorConfig 为单个地址族的检测配置
+type DetectorConfig struct {
+	// Strategy 为空时使用 StrategyFirst
+	Strategy string
+	// Quorum 为 StrategyQuorum 下至少一致的来源数，0 表示过半
+	Quorum int
+	// AllowPrivate 为 true 时接受私有与保留地址，用于内网
+	AllowPrivate bool
+	// Timeout 为单个来源的超时，默认 10s
+	Timeout time.Duration
+}
+
+// Detector 从多个来源获取公网 IP，拒绝私有与保留地址
+type Detector struct {
+	conf    DetectorConfig
+	sources []Source
+}
+
+func NewDetector(conf DetectorConfig, sources ...Source) (*Detector, error) {
+	if len(sources) == 0 {
+		return nil, errutil.WrapF("no ip source configured")
+	}
+	switch conf.Strategy {
+	case "":
+		conf.Strategy = StrategyFirst
+	case StrategyFirst, StrategyQuorum:
+	default:
+		return nil, errutil.WrapF("unknown ip source strategy %q", conf.Strategy)
+	}
+	if conf.Quorum > len(sources) {
+		return nil, errutil.WrapF("quorum %d exceeds %d ip sources", conf.Quorum, len(sources))
+	}
+	if conf.Quorum <= 0 {
+		conf.Quorum = len(sources)/2 + 1
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = defaultSourceTimeout
+	}
+	return &Detector{conf: conf, sources: sources}, nil
+}
+
+// Detect 获取 family 的公网 IP
+func (d *Detector) Detect(ctx context.Context, family Family) (string, error) {
+	if d.conf.Strategy == StrategyQuorum {
+		return d.quorum(ctx, family)
+	}
+	return d.first(ctx, family)
+}
+
+func (d *Detector) first(ctx context.Context, family Family) (string, error) {
+	var errs []error
+	for _, s := range d.sources {
+		addr, err := d.query(ctx, s, family)
+		if err == nil {
+			return addr.String(), nil
+		}
+		log.WarnC(ctx, "get ip from source failed", "source", s.Name(), "family", family, "err", err)
+		errs = append(errs, err)
+	}
+	return "", errutil.WrapF("all ip sources failed: %w", errors.Join(errs...))
+}
+
+func (d *Detector) quorum(ctx context.Context, family Family) (string, error) {
+	addrs := make([]netip.Addr, len(d.sources))
+	errs := make([]error, len(d.sources))
+	var wg sync.WaitGroup
+	for i, s := range d.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs[i], errs[i] = d.query(ctx, s, family)
+		}()
+	}
+	wg.Wait()
+
+	votes := make(map[netip.Addr]int)
+	var best netip.Addr
+	var results []string
+	for i, s := range d.sources {
+		if errs[i] != nil {
+			log.WarnC(ctx, "get ip from source failed", "source", s.Name(), "family", family, "err", errs[i])
+			results = append(results, fmt.Sprintf("%s: %v", s.Name(), errs[i]))
+			continue
+		}
+		votes[addrs[i]]++
+		if votes[addrs[i]] > votes[best] {
+			best = addrs[i]
+		}
+		results = append(results, fmt.Sprintf("%s: %s", s.Name(), addrs[i]))
+	}
+	if votes[best] < d.conf.Quorum {
+		return "", errutil.WrapF("ip sources disagree, want %d in agreement: %s",
+			d.conf.Quorum, strings.Join(results, "; "))
+	}
+	return best.String(), nil
+}
+
+// query 从单个来源获取地址，返回第一个通过 check 的地址
+func (d *Detector) query(ctx context.Context, s Source, family Family) (netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.conf.Timeout)
+	defer cancel()
+	var addrs []netip.Addr
+	if ms, ok := s.(multiSource); ok {
+		var err error
+		addrs, err = ms.GetIps(ctx, family)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+	} else {
+		addr, err := s.GetIp(ctx, family)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		addrs = append(addrs, addr)
+	}
+	var errs []error
+	for _, addr := range addrs {
+		addr, err := d.check(s, family, addr)
+		if err == nil {
+			return addr, nil
+		}
+		errs = append(errs, err)
+	}
+	return netip.Addr{}, errors.Join(errs...)
+}
+
+// check 校验地址族与是否为公网地址，所有来源的地址均在此过滤
+func (d *Detector) check(s Source, family Family, addr netip.Addr) (netip.Addr, error) {
+	addr = addr.Unmap()
+	if !family.match(addr) {
+		return netip.Addr{}, errutil.WrapF("got %s from %s, want %s", addr, s.Name(), family)
+	}
+	if !d.conf.AllowPrivate && !isPublic(addr) {
+		return netip.Addr{}, errutil.WrapF("got non-public address %s from %s", addr, s.Name())
+	}
+	return addr, nil
+}
@@ -0,0 +1,238 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	name string
+	ip   string
+	err  error
+}
+
+func (s *fakeSource) Name() string {
+	return s.name
+}
+
+func (s *fakeSource) GetIp(context.Context, Family) (netip.Addr, error) {
+	if s.err != nil {
+		return netip.Addr{}, s.err
+	}
+	return netip.ParseAddr(s.ip)
+}
+
+// fakeMultiSource 模拟网卡等返回多个地址的来源
+type fakeMultiSource struct {
+	fakeSource
+	ips []string
+}
+
+func (s *fakeMultiSource) GetIps(context.Context, Family) ([]netip.Addr, error) {
+	var res []netip.Addr
+	for _, ip := range s.ips {
+		res = append(res, netip.MustParseAddr(ip))
+	}
+	return res, nil
+}
+
+func TestDetectorMultiSource(t *testing.T) {
+	ctx := context.Background()
+	s := &fakeMultiSource{fakeSource: fakeSource{name: "iface"}, ips: []string{"fd00::1", "10.0.0.1", "192.168.1.2"}}
+
+	d, err := NewDetector(DetectorConfig{}, s)
+	require.NoError(t, err)
+	_, err = d.Detect(ctx, FamilyIpv4)
+	assert.ErrorContains(t, err, "non-public address 10.0.0.1")
+
+	// allow_private 对网卡来源同样生效
+	d, err = NewDetector(DetectorConfig{AllowPrivate: true}, s)
+	require.NoError(t, err)
+	ip, err := d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip)
+	ip, err = d.Detect(ctx, FamilyIpv6)
+	require.NoError(t, err)
+	assert.Equal(t, "fd00::1", ip)
+
+	s.ips = []string{"192.168.1.2", "1.1.1.1"}
+	d, err = NewDetector(DetectorConfig{}, s)
+	require.NoError(t, err)
+	ip, err = d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", ip)
+}
+
+func TestDetectorFirst(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDetector(DetectorConfig{},
+		&fakeSource{name: "down", err: errors.New("connection refused")},
+		&fakeSource{name: "private", ip: "192.168.1.2"},
+		&fakeSource{name: "cgnat", ip: "100.64.1.2"},
+		&fakeSource{name: "v6", ip: "240e:1::1"},
+		&fakeSource{name: "ok", ip: "1.1.1.1"},
+		&fakeSource{name: "other", ip: "2.2.2.2"},
+	)
+	require.NoError(t, err)
+	ip, err := d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", ip)
+
+	d, err = NewDetector(DetectorConfig{AllowPrivate: true}, &fakeSource{name: "private", ip: "192.168.1.2"})
+	require.NoError(t, err)
+	ip, err = d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", ip)
+
+	d, err = NewDetector(DetectorConfig{}, &fakeSource{name: "private", ip: "10.0.0.1"})
+	require.NoError(t, err)
+	_, err = d.Detect(ctx, FamilyIpv4)
+	assert.ErrorContains(t, err, "non-public address 10.0.0.1")
+}
+
+func TestDetectorQuorum(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDetector(DetectorConfig{Strategy: StrategyQuorum},
+		&fakeSource{name: "a", ip: "1.1.1.1"},
+		&fakeSource{name: "b", ip: "::ffff:1.1.1.1"},
+		&fakeSource{name: "c", ip: "2.2.2.2"},
+	)
+	require.NoError(t, err)
+	ip, err := d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", ip)
+
+	// 默认需要过半来源一致
+	d, err = NewDetector(DetectorConfig{Strategy: StrategyQuorum},
+		&fakeSource{name: "a", ip: "1.1.1.1"},
+		&fakeSource{name: "b", err: errors.New("timeout")},
+		&fakeSource{name: "c", ip: "2.2.2.2"},
+	)
+	require.NoError(t, err)
+	_, err = d.Detect(ctx, FamilyIpv4)
+	assert.ErrorContains(t, err, "want 2 in agreement")
+
+	d, err = NewDetector(DetectorConfig{Strategy: StrategyQuorum, Quorum: 1},
+		&fakeSource{name: "a", err: errors.New("timeout")},
+		&fakeSource{name: "b", ip: "2.2.2.2"},
+	)
+	require.NoError(t, err)
+	ip, err = d.Detect(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "2.2.2.2", ip)
+
+	_, err = NewDetector(DetectorConfig{Strategy: StrategyQuorum, Quorum: 3}, &fakeSource{name: "a"})
+	assert.Error(t, err)
+	_, err = NewDetector(DetectorConfig{Strategy: "vote"}, &fakeSource{name: "a"})
+	assert.Error(t, err)
+	_, err = NewDetector(DetectorConfig{})
+	assert.Error(t, err)
+}
+
+func TestHttpSource(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "1.2.3.4\n")
+	}))
+	defer s.Close()
+
+	src, err := NewSource(SourceConfig{Type: SourceHttp, Url: s.URL})
+	require.NoError(t, err)
+	addr, err := src.GetIp(context.Background(), FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", addr.String())
+
+	_, err = NewSource(SourceConfig{Type: SourceHttp})
+	assert.Error(t, err)
+	_, err = NewSource(SourceConfig{Type: SourceNatpmp})
+	assert.Error(t, err)
+}
+
+func TestUpnpExternalIp(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType>
+        <controlURL>/l3f</controlURL>
+      </service>
+    </serviceList>
+    <deviceList><device><deviceList><device>
+      <serviceList>
+        <service>
+          <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+          <controlURL>/ctl/IPConn</controlURL>
+        </service>
+      </serviceList>
+    </device></deviceList></device></deviceList>
+  </device>
+</root>`)
+	})
+	mux.HandleFunc("POST /ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"`, r.Header.Get("SOAPAction"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), "GetExternalIPAddress")
+		_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>8.8.4.4</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	src, err := NewSource(SourceConfig{Type: SourceUpnp, Location: s.URL + "/desc.xml"})
+	require.NoError(t, err)
+	addr, err := src.GetIp(context.Background(), FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "8.8.4.4", addr.String())
+
+	_, err = src.GetIp(context.Background(), FamilyIpv6)
+	assert.Error(t, err)
+}
+
+func TestNatpmpSource(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 16)
+		for first := true; ; first = false {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// 丢弃第一个请求以验证重传
+			if first || n != 2 {
+				continue
+			}
+			_, _ = conn.WriteTo([]byte{0, 128, 0, 0, 0, 0, 0, 1, 5, 6, 7, 8}, addr)
+		}
+	}()
+
+	src, err := NewSource(SourceConfig{Type: SourceNatpmp, Gateway: conn.LocalAddr().String()})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addr, err := src.GetIp(ctx, FamilyIpv4)
+	require.NoError(t, err)
+	assert.Equal(t, "5.6.7.8", addr.String())
+
+	_, err = parseNatpmp([]byte{0, 128, 0, 3, 0, 0, 0, 1, 0, 0, 0, 0})
+	assert.ErrorContains(t, err, "result code 3")
+	_, err = parseNatpmp([]byte{0, 129})
+	assert.ErrorContains(t, err, "invalid natpmp response")
+}
@@ -0,0 +1,138 @@
+package ddns
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	_ "modernc.org/sqlite"
+)
+
+// RecordState 为记录同步状态的持久化形式，monitor 重启后据此跳过未变化的记录
+type RecordState struct {
+	Key      string `gorm:"primaryKey" json:"key"`
+	Value    string `json:"value"`
+	RecordId string `json:"record_id"`
+	// Synced 为最近一次成功写入的时间
+	Synced time.Time `json:"synced"`
+	// Checked 为最近一次同步的时间，无论成功与否
+	Checked time.Time `json:"checked"`
+	// Failed 为最近一次失败的时间
+	Failed time.Time `json:"failed"`
+	Err    string    `json:"err,omitempty"`
+	// Failures 为连续失败的次数
+	Failures int `json:"failures"`
+}
+
+// Change 为一次记录值的变更
+type Change struct {
+	Id       uint      `gorm:"primaryKey" json:"id"`
+	Key      string    `gorm:"index" json:"key"`
+	Old      string    `json:"old"`
+	New      string    `json:"new"`
+	RecordId string    `json:"record_id"`
+	Time     time.Time `gorm:"index" json:"time"`
+}
+
+// Store 将记录状态与变更历史保存在 SQLite 中
+type Store struct {
+	db *gorm.DB
+}
+
+// OpenStore 打开 path 处的数据库，不存在时创建
+func OpenStore(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	// 使用纯 Go 实现的 modernc.org/sqlite，不依赖 cgo
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: "sqlite", DSN: path}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, errutil.WrapF("open ddns store %s failed: %w", path, err)
+	}
+	err = db.AutoMigrate(&RecordState{}, &Change{})
+	if err != nil {
+		return nil, errutil.WrapF("migrate ddns store %s failed: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// OpenStoreReadOnly 以只读方式打开已存在的数据库，不创建文件与表，用于查询状态与历史
+func OpenStoreReadOnly(path string) (*Store, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errutil.WrapF("no state file at %s", path)
+	}
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	dsn := (&url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}).String()
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: "sqlite", DSN: dsn}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, errutil.WrapF("open ddns store %s failed: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	db, err := s.db.DB()
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	err = db.Close()
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+// States 返回所有记录的状态，按 Key 排序
+func (s *Store) States() ([]RecordState, error) {
+	var res []RecordState
+	err := s.db.Order("key").Find(&res).Error
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	return res, nil
+}
+
+func (s *Store) SaveState(st RecordState) error {
+	err := s.db.Save(&st).Error
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+func (s *Store) AddChange(c Change) error {
+	err := s.db.Create(&c).Error
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+// History 返回最近的变更，按时间倒序。key 为空时返回所有记录的变更，limit 不大于 0 时不限制
+func (s *Store) History(key string, limit int) ([]Change, error) {
+	q := s.db.Order("time desc, id desc")
+	if key != "" {
+		q = q.Where("key = ?", key)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var res []Change
+	err := q.Find(&res).Error
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	return res, nil
+}
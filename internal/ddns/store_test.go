@@ -0,0 +1,85 @@
+package ddns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state", "ddns.db")
+	store, err := OpenStore(path)
+	require.NoError(t, err)
+
+	p := newMemProvider()
+	targets := []Target{{Domain: "example.com", Name: "www", Type: TypeA, Create: true}}
+	s, err := NewSyncer(map[string]Provider{"": p}, targets)
+	require.NoError(t, err)
+	require.NoError(t, s.SetStore(store))
+	require.NoError(t, s.Sync(ctx, map[Family]string{FamilyIpv4: "1.1.1.1"}))
+	require.NoError(t, s.Sync(ctx, map[Family]string{FamilyIpv4: "2.2.2.2"}))
+	require.NoError(t, store.Close())
+
+	// 重启后恢复状态，值未变化时不访问服务商
+	store, err = OpenStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	s, err = NewSyncer(map[string]Provider{"": p}, targets)
+	require.NoError(t, err)
+	require.NoError(t, s.SetStore(store))
+	gets, upserts := p.gets, p.upserts
+	require.NoError(t, s.Sync(ctx, map[Family]string{FamilyIpv4: "2.2.2.2"}))
+	assert.Equal(t, gets, p.gets)
+	assert.Equal(t, upserts, p.upserts)
+	st := s.Status()[0]
+	assert.Equal(t, "2.2.2.2", st.Value)
+	assert.NotEmpty(t, st.RecordId)
+	assert.False(t, st.Synced.IsZero())
+
+	states, err := store.States()
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "/www.example.com/A/", states[0].Key)
+
+	history, err := store.History("", 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "1.1.1.1", history[0].Old)
+	assert.Equal(t, "2.2.2.2", history[0].New)
+	assert.Equal(t, "", history[1].Old)
+
+	history, err = store.History("/www.example.com/A/", 1)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+	history, err = store.History("other", 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestOpenStoreReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	_, err := OpenStoreReadOnly(filepath.Join(dir, "missing", "ddns.db"))
+	assert.ErrorContains(t, err, "no state file at")
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	path := filepath.Join(dir, "ddns #1.db")
+	store, err := OpenStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveState(RecordState{Key: "/www.example.com/A/", Value: "1.1.1.1"}))
+	require.NoError(t, store.Close())
+
+	store, err = OpenStoreReadOnly(path)
+	require.NoError(t, err)
+	defer store.Close()
+	states, err := store.States()
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "1.1.1.1", states[0].Value)
+	assert.Error(t, store.SaveState(RecordState{Key: "other"}))
+}
@@ -0,0 +1,295 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// Target 为需要保持同步的一条记录，对应配置 ddns.records 中的一项
+type Target struct {
+	// Account 为 ddns.accounts 中的服务商账号，为空时使用 ddns.provider
+	Account string `mapstructure:"account"`
+	Domain  string `mapstructure:"domain" validate:"required,fqdn"`
+	Name    string `mapstructure:"sub_domain"`
+	// Type 为空时同时维护 A 与 AAAA 记录
+	Type     string `mapstructure:"type" validate:"omitempty,oneof=A AAAA"`
+	Line     string `mapstructure:"line"`
+	TTL      int    `mapstructure:"ttl" validate:"min=0"`
+	RecordId string `mapstructure:"record_id"`
+	// Create 为 true 时记录不存在则创建，否则视为失败
+	Create bool `mapstructure:"create"`
+}
+
+// Key 唯一标识一条记录
+func (t Target) Key() string {
+	return t.Account + "/" + Fqdn(t.Domain, t.Name) + "/" + t.Type + "/" + t.Line
+}
+
+func (t Target) family() Family {
+	if t.Type == TypeAAAA {
+		return FamilyIpv6
+	}
+	return FamilyIpv4
+}
+
+func (t Target) record(value string) Record {
+	return Record{
+		Id:     t.RecordId,
+		Domain: t.Domain,
+		Name:   t.Name,
+		Type:   t.Type,
+		Value:  value,
+		TTL:    t.TTL,
+		Line:   t.Line,
+	}
+}
+
+// Status 为单条记录最近一次同步的结果，Value 为最近一次成功写入的值
+type Status struct {
+	Target Target `json:"target"`
+	RecordState
+}
+
+// Syncer 将各地址族的 IP 同步到多条记录，单条记录失败不影响其他记录
+type Syncer struct {
+	providers map[string]Provider
+	targets   []Target
+
+	store     *Store
+	notifier  Notifier
+	threshold int
+
+	lock   sync.Mutex
+	status map[string]*Status
+}
+
+// NewSyncer 校验 targets 引用的账号均存在，并将未指定类型的 target 展开为 A 与 AAAA
+func NewSyncer(providers map[string]Provider, targets []Target) (*Syncer, error) {
+	s := &Syncer{providers: providers, status: make(map[string]*Status)}
+	for _, t := range targets {
+		if _, ok := providers[t.Account]; !ok {
+			return nil, errutil.WrapNotFound("ddns account " + t.Account)
+		}
+		types := []string{t.Type}
+		if t.Type == "" {
+			if t.RecordId != "" {
+				return nil, errutil.WrapF("record %s: record_id requires type", Fqdn(t.Domain, t.Name))
+			}
+			types = []string{TypeA, TypeAAAA}
+		}
+		for _, typ := range types {
+			t.Type = typ
+			if _, ok := s.status[t.Key()]; ok {
+				return nil, errutil.WrapF("duplicate record %s", t.Key())
+			}
+			s.targets = append(s.targets, t)
+			s.status[t.Key()] = &Status{Target: t, RecordState: RecordState{Key: t.Key(), RecordId: t.RecordId}}
+		}
+	}
+	if len(s.targets) == 0 {
+		return nil, errutil.WrapF("no ddns record configured")
+	}
+	return s, nil
+}
+
+// SetStore 从 store 恢复记录的状态，之后每次同步保存状态与变更历史
+func (s *Syncer) SetStore(store *Store) error {
+	states, err := store.States()
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, state := range states {
+		st, ok := s.status[state.Key]
+		if !ok {
+			continue
+		}
+		// 配置的 record_id 优先于保存的
+		if st.Target.RecordId != "" {
+			state.RecordId = st.Target.RecordId
+		}
+		st.RecordState = state
+	}
+	s.store = store
+	return nil
+}
+
+// SetNotifier 在记录值变化、连续失败 threshold 次以及之后恢复时发送通知，threshold 为 0 时不通知失败
+func (s *Syncer) SetNotifier(n Notifier, threshold int) {
+	s.notifier = n
+	s.threshold = threshold
+}
+
+// Families 返回 targets 涉及的地址族
+func (s *Syncer) Families() []Family {
+	var fs []Family
+	seen := make(map[Family]bool)
+	for _, t := range s.targets {
+		if f := t.family(); !seen[f] {
+			seen[f] = true
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// Sync 将 ips 中各地址族的值写入对应记录，没有该地址族的记录跳过，
+// 值未变化且上次成功的记录不重复写入。返回所有失败记录的错误
+func (s *Syncer) Sync(ctx context.Context, ips map[Family]string) error {
+	var errs []error
+	for _, t := range s.targets {
+		ip, ok := ips[t.family()]
+		if !ok {
+			continue
+		}
+		err := s.syncOne(ctx, t, ip)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Syncer) syncOne(ctx context.Context, t Target, ip string) error {
+	s.lock.Lock()
+	st := *s.status[t.Key()]
+	s.lock.Unlock()
+	if st.Value == ip && st.Err == "" {
+		log.DebugC(ctx, "record has not changed, do nothing", "record", t.Key(), "value", ip)
+		return nil
+	}
+
+	old := st.Value
+	res, err := s.write(ctx, t, &st, ip)
+	st.Checked = time.Now()
+	var events []string
+	if err != nil {
+		err = errutil.WrapF("sync record %s failed: %w", t.Key(), err)
+		st.Err = err.Error()
+		st.Failed = st.Checked
+		st.Failures++
+		if st.Failures == s.threshold {
+			events = append(events, EventFailed)
+		}
+		log.ErrorC(ctx, "refresh record failed", "record", t.Key(), "value", ip, "failures", st.Failures, "err", err)
+	} else {
+		if s.threshold > 0 && st.Failures >= s.threshold {
+			events = append(events, EventRecovered)
+		}
+		st.Err = ""
+		st.Failures = 0
+		if old != ip {
+			st.Synced = st.Checked
+			events = append(events, EventChanged)
+		}
+		st.Value = ip
+		if res.Id != "" {
+			st.RecordId = res.Id
+		}
+	}
+	s.lock.Lock()
+	s.status[t.Key()] = &st
+	s.lock.Unlock()
+
+	s.persist(ctx, st, old)
+	for _, typ := range events {
+		s.notify(ctx, newEvent(typ, t, &st, old))
+	}
+	return err
+}
+
+// persist 保存状态，值变化时记录历史，失败只记录日志
+func (s *Syncer) persist(ctx context.Context, st Status, old string) {
+	if s.store == nil {
+		return
+	}
+	err := s.store.SaveState(st.RecordState)
+	if err == nil && st.Err == "" && old != st.Value {
+		err = s.store.AddChange(Change{Key: st.Key, Old: old, New: st.Value, RecordId: st.RecordId, Time: st.Checked})
+	}
+	if err != nil {
+		log.ErrorC(ctx, "save ddns state failed", "record", st.Key, "err", err)
+	}
+}
+
+func (s *Syncer) notify(ctx context.Context, e Event) {
+	if s.notifier == nil {
+		return
+	}
+	err := s.notifier.Notify(ctx, e)
+	if err != nil {
+		log.ErrorC(ctx, "send ddns notification failed", "event", e.Type, "record", e.Record, "err", err)
+	}
+}
+
+// write 将 ip 写入记录。远端状态未知时 (首次同步或上次失败) 先查找记录，
+// 缓存其 ID，值已一致时不再写入；缓存的 ID 失效时重新查找一次
+func (s *Syncer) write(ctx context.Context, t Target, st *Status, ip string) (Record, error) {
+	p := s.providers[t.Account]
+	r := t.record(ip)
+	r.Id = st.RecordId
+	lookup := r.Id == "" || st.Value == "" || st.Err != ""
+	for retry := true; ; retry = false {
+		if lookup {
+			cur, err := p.GetRecord(ctx, t.record(""))
+			switch {
+			case errors.Is(err, errutil.ErrNotFound):
+				if r.Id != "" {
+					// 按名称未找到时仍尝试已知的 record_id，失效时再按是否创建处理
+					break
+				}
+				if !t.Create {
+					return Record{}, errutil.WrapF("%w, set create to create it", err)
+				}
+			case err != nil:
+				return Record{}, err
+			// 配置了 record_id 时以其为准，查到的其他记录不参与比较
+			case t.RecordId == "" || cur.Id == t.RecordId:
+				if cur.Id != "" {
+					st.RecordId = cur.Id
+					r.Id = cur.Id
+				}
+				if cur.Value == ip && (t.TTL == 0 || cur.TTL == t.TTL) {
+					log.DebugC(ctx, "remote record is up to date", "record", t.Key(), "value", ip, "record_id", cur.Id)
+					return cur, nil
+				}
+			}
+		}
+
+		log.WarnC(ctx, "begin refresh record", "record", t.Key(), "value", ip, "record_id", r.Id)
+		res, err := p.UpsertRecord(ctx, r)
+		if errors.Is(err, errutil.ErrNotFound) && r.Id != "" && retry {
+			log.WarnC(ctx, "record id is stale, look up again", "record", t.Key(), "record_id", r.Id)
+			st.RecordId = ""
+			r.Id = ""
+			lookup = true
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		log.WarnC(ctx, "refresh record success", "record", t.Key(), "value", ip, "record_id", res.Id)
+		return res, nil
+	}
+}
+
+// Status 返回所有记录的状态，按 Key 排序
+func (s *Syncer) Status() []Status {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := make([]Status, 0, len(s.status))
+	for _, st := range s.status {
+		res = append(res, *st)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Target.Key() < res[j].Target.Key()
+	})
+	return res
+}
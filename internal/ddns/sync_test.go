@@ -0,0 +1,169 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// memProvider 为基于 fakeZone 的 Provider，fail 中的主机记录写入失败
+type memProvider struct {
+	zone    *fakeZone
+	fail    map[string]bool
+	gets    int
+	upserts int
+}
+
+func newMemProvider() *memProvider {
+	return &memProvider{zone: newFakeZone(), fail: make(map[string]bool)}
+}
+
+func (p *memProvider) GetRecord(_ context.Context, r Record) (Record, error) {
+	p.gets++
+	res, ok := p.zone.get(r.Fqdn(), r.Type)
+	if !ok {
+		return Record{}, notFound(r)
+	}
+	return res, nil
+}
+
+func (p *memProvider) UpsertRecord(_ context.Context, r Record) (Record, error) {
+	p.upserts++
+	if p.fail[r.Fqdn()] {
+		return Record{}, errors.New("mock failure")
+	}
+	if r.Id != "" {
+		fqdn := r.Fqdn()
+		r.Name = fqdn
+		if !p.zone.modify(r.Id, r) {
+			return Record{}, errutil.WrapNotFound("record " + r.Id)
+		}
+		return r, nil
+	}
+	if cur, ok := p.zone.get(r.Fqdn(), r.Type); ok {
+		r.Name = r.Fqdn()
+		p.zone.modify(cur.Id, r)
+		r.Id = cur.Id
+		return r, nil
+	}
+	r.Name = r.Fqdn()
+	return p.zone.create(r), nil
+}
+
+func (p *memProvider) DeleteRecord(_ context.Context, r Record) error {
+	if !p.zone.delete(r.Id) {
+		return notFound(r)
+	}
+	return nil
+}
+
+func TestSyncer(t *testing.T) {
+	ctx := context.Background()
+	def, cf := newMemProvider(), newMemProvider()
+	s, err := NewSyncer(map[string]Provider{"": def, "cf": cf}, []Target{
+		{Domain: "example.com", Name: "www", Create: true},
+		{Domain: "example.org", Name: "@", Type: TypeA, Account: "cf", Create: true},
+		{Domain: "example.net", Name: "home", Type: TypeAAAA, Create: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Family{FamilyIpv4, FamilyIpv6}, s.Families())
+	assert.Len(t, s.Status(), 4)
+
+	// 单条记录失败不影响其他记录
+	cf.fail["example.org"] = true
+	err = s.Sync(ctx, map[Family]string{FamilyIpv4: "1.1.1.1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cf/example.org/A/")
+	r, ok := def.zone.get("www.example.com", TypeA)
+	require.True(t, ok)
+	assert.Equal(t, "1.1.1.1", r.Value)
+	_, ok = def.zone.get("www.example.com", TypeAAAA)
+	assert.False(t, ok)
+
+	for _, st := range s.Status() {
+		switch st.Target.Key() {
+		case "cf/example.org/A/":
+			assert.NotEmpty(t, st.Err)
+			assert.Empty(t, st.Value)
+		case "/www.example.com/A/":
+			assert.Empty(t, st.Err)
+			assert.Equal(t, "1.1.1.1", st.Value)
+			assert.Equal(t, r.Id, st.RecordId)
+		}
+	}
+
+	// 未变化的记录跳过，失败的记录重试
+	cf.fail["example.org"] = false
+	defUpserts, cfUpserts := def.upserts, cf.upserts
+	err = s.Sync(ctx, map[Family]string{FamilyIpv4: "1.1.1.1", FamilyIpv6: "::1"})
+	require.NoError(t, err)
+	assert.Equal(t, defUpserts+2, def.upserts)
+	assert.Equal(t, cfUpserts+1, cf.upserts)
+	r, ok = cf.zone.get("example.org", TypeA)
+	require.True(t, ok)
+	assert.Equal(t, "1.1.1.1", r.Value)
+	for _, st := range s.Status() {
+		assert.Empty(t, st.Err, st.Target.Key())
+	}
+}
+
+func TestSyncerLookup(t *testing.T) {
+	ctx := context.Background()
+	p := newMemProvider()
+	existing := p.zone.create(Record{Name: "www.example.com", Type: TypeA, Value: "1.1.1.1"})
+	s, err := NewSyncer(map[string]Provider{"": p}, []Target{
+		{Domain: "example.com", Name: "www", Type: TypeA},
+		{Domain: "example.com", Name: "new", Type: TypeA},
+	})
+	require.NoError(t, err)
+
+	// 远端值一致时不写入，并缓存查到的 ID；不存在且未开启 create 时失败
+	err = s.Sync(ctx, map[Family]string{FamilyIpv4: "1.1.1.1"})
+	assert.ErrorContains(t, err, "set create to create it")
+	assert.Equal(t, 0, p.upserts)
+	_, ok := p.zone.get("new.example.com", TypeA)
+	assert.False(t, ok)
+	st := s.Status()
+	assert.Equal(t, "/www.example.com/A/", st[1].Target.Key())
+	assert.Equal(t, existing.Id, st[1].RecordId)
+	assert.Equal(t, "1.1.1.1", st[1].Value)
+
+	// 使用缓存的 ID 直接更新，不再查找
+	gets := p.gets
+	_ = s.Sync(ctx, map[Family]string{FamilyIpv4: "2.2.2.2"})
+	r, _ := p.zone.get("www.example.com", TypeA)
+	assert.Equal(t, "2.2.2.2", r.Value)
+	assert.Equal(t, gets+1, p.gets, "only the missing record is looked up")
+
+	// 缓存的 ID 失效时重新查找
+	require.True(t, p.zone.delete(existing.Id))
+	recreated := p.zone.create(Record{Name: "www.example.com", Type: TypeA, Value: "2.2.2.2"})
+	_ = s.Sync(ctx, map[Family]string{FamilyIpv4: "3.3.3.3"})
+	r, _ = p.zone.get("www.example.com", TypeA)
+	assert.Equal(t, "3.3.3.3", r.Value)
+	assert.Equal(t, recreated.Id, r.Id)
+	assert.Equal(t, recreated.Id, s.Status()[1].RecordId)
+}
+
+func TestNewSyncer(t *testing.T) {
+	ps := map[string]Provider{"": newMemProvider()}
+
+	_, err := NewSyncer(ps, []Target{{Domain: "example.com", Account: "cf"}})
+	assert.True(t, errors.Is(err, errutil.ErrNotFound), "%v", err)
+
+	_, err = NewSyncer(ps, []Target{
+		{Domain: "example.com", Type: TypeA},
+		{Domain: "example.com"},
+	})
+	assert.ErrorContains(t, err, "duplicate record")
+
+	_, err = NewSyncer(ps, []Target{{Domain: "example.com", RecordId: "1"}})
+	assert.ErrorContains(t, err, "record_id requires type")
+
+	_, err = NewSyncer(ps, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,205 @@
+package ddns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const (
+	ssdpAddr        = "239.255.255.250:1900"
+	upnpGatewayType = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+)
+
+type upnpSource struct {
+	location string
+}
+
+func (s *upnpSource) Name() string {
+	if s.location != "" {
+		return SourceUpnp + " " + s.location
+	}
+	return SourceUpnp
+}
+
+func (s *upnpSource) GetIp(ctx context.Context, family Family) (netip.Addr, error) {
+	if family != FamilyIpv4 {
+		return netip.Addr{}, errutil.WrapF("upnp only supports ipv4")
+	}
+	location := s.location
+	if location == "" {
+		var err error
+		location, err = ssdpDiscover(ctx)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+	}
+	return upnpExternalIp(ctx, location)
+}
+
+// ssdpDiscover 通过 SSDP 组播发现网关，返回其设备描述地址
+func ssdpDiscover(ctx context.Context) (string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(3 * time.Second)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + upnpGatewayType + "\r\n\r\n"
+	if _, err = conn.WriteTo([]byte(req), dst); err != nil {
+		return "", errutil.Wrap(err)
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", errutil.WrapF("discover upnp gateway failed: %w", err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		_ = resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// upnpExternalIp 从设备描述中找到 WANIPConnection 或 WANPPPConnection 服务，
+// 调用其 GetExternalIPAddress
+func upnpExternalIp(ctx context.Context, location string) (netip.Addr, error) {
+	c := httpclient.New(httpclient.Config{RetryCount: -1})
+	resp, err := c.R(ctx).Get(location)
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+	if !resp.IsSuccess() {
+		return netip.Addr{}, errutil.WrapF("get %s failed: %s", location, resp.Status())
+	}
+	svc, base, err := findWanService(bytes.NewReader(resp.Body()))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if base == "" {
+		base = location
+	}
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+	control, err := baseUrl.Parse(svc.ControlURL)
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+
+	body := fmt.Sprintf(`<?xml version="1.0"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`+
+		`<s:Body><u:GetExternalIPAddress xmlns:u="%s"/></s:Body></s:Envelope>`, svc.ServiceType)
+	resp, err = c.R(ctx).
+		SetHeader("Content-Type", `text/xml; charset="utf-8"`).
+		SetHeader("SOAPAction", `"`+svc.ServiceType+`#GetExternalIPAddress"`).
+		SetBody(body).
+		Post(control.String())
+	if err != nil {
+		return netip.Addr{}, errutil.Wrap(err)
+	}
+	if !resp.IsSuccess() {
+		return netip.Addr{}, errutil.WrapF("upnp GetExternalIPAddress failed: %s", resp.Status())
+	}
+	ip, err := xmlText(bytes.NewReader(resp.Body()), "NewExternalIPAddress")
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return netip.Addr{}, errutil.WrapF("invalid upnp external address: %w", err)
+	}
+	return addr, nil
+}
+
+// findWanService 返回设备描述中的 WAN 连接服务与 URLBase
+func findWanService(r io.Reader) (upnpService, string, error) {
+	var base string
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return upnpService{}, "", errutil.WrapNotFound("upnp WAN connection service")
+		}
+		if err != nil {
+			return upnpService{}, "", errutil.Wrap(err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "URLBase":
+			if err = d.DecodeElement(&base, &start); err != nil {
+				return upnpService{}, "", errutil.Wrap(err)
+			}
+		case "service":
+			var svc upnpService
+			if err = d.DecodeElement(&svc, &start); err != nil {
+				return upnpService{}, "", errutil.Wrap(err)
+			}
+			if strings.Contains(svc.ServiceType, ":WANIPConnection:") ||
+				strings.Contains(svc.ServiceType, ":WANPPPConnection:") {
+				return svc, strings.TrimSpace(base), nil
+			}
+		}
+	}
+}
+
+// xmlText 返回第一个名为 name 的元素的文本
+func xmlText(r io.Reader, name string) (string, error) {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return "", errutil.WrapNotFound("xml element " + name)
+		}
+		if err != nil {
+			return "", errutil.Wrap(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == name {
+			var s string
+			if err = d.DecodeElement(&s, &start); err != nil {
+				return "", errutil.Wrap(err)
+			}
+			return s, nil
+		}
+	}
+}
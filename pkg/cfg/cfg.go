@@ -1,11 +1,16 @@
 package cfg
 
 import (
+	"bytes"
 	_ "embed"
 	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
 	"github.com/vksir/vkiss-lib/pkg/util/errutil"
 	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -13,18 +18,76 @@ var (
 		"log level").SetDefault("info").SetPersistent(true)
 	LogPath = NewFlag[string]("log-path", "log.path",
 		"log path").SetPersistent(true)
+	LogMaxSize = NewFlag[int]("log-max-size", "log.max_size",
+		"max size (MB) of log file before rotation, 0 to disable").SetPersistent(true).SetValidate("min=0")
+	LogRotateInterval = NewFlag[time.Duration]("log-rotate-interval", "log.rotate_interval",
+		"rotate log file every interval, 0 to disable").SetPersistent(true).SetValidate("min=0")
+	LogMaxBackups = NewFlag[int]("log-max-backups", "log.max_backups",
+		"max number of rotated log files to keep, 0 to keep all").SetPersistent(true).SetValidate("min=0")
+	LogMaxAge = NewFlag[time.Duration]("log-max-age", "log.max_age",
+		"max age of rotated log files, 0 to keep forever").SetPersistent(true).SetValidate("min=0")
+	LogCompress = NewFlag[bool]("log-compress", "log.compress",
+		"gzip rotated log files").SetPersistent(true)
+	LogFormat = NewFlag[string]("log-format", "log.format",
+		"log format, text or json").SetDefault(log.FormatText).SetPersistent(true).SetValidate("oneof=text json")
+	LogConsoleLevel = NewFlag[string]("log-console-level", "log.console_level",
+		"log level of stdout, follow log.level if empty").SetPersistent(true)
+	LogFileLevel = NewFlag[string]("log-file-level", "log.file_level",
+		"log level of log file, follow log.level if empty").SetPersistent(true)
+	LogModules = NewFlag[map[string]string]("log-modules", "log.modules",
+		"log level of named loggers, e.g. steamcmd=debug").SetPersistent(true)
+	LogRingSize = NewFlag[int]("log-ring-size", "log.ring_size",
+		"number of recent log records kept in memory, 0 to disable").SetPersistent(true).SetValidate("min=0")
+)
+
+// LogConfig 汇总 log.* 配置
+func LogConfig() log.Config {
+	return log.Config{
+		Path:         LogPath.Get(),
+		Level:        LogLevel.Get(),
+		Format:       LogFormat.Get(),
+		ConsoleLevel: LogConsoleLevel.Get(),
+		FileLevel:    LogFileLevel.Get(),
+		Modules:      LogModules.Get(),
+		RingSize:     LogRingSize.Get(),
+		Rotate: log.RotateConfig{
+			MaxSize:    int64(LogMaxSize.Get()) * 1024 * 1024,
+			Interval:   LogRotateInterval.Get(),
+			MaxBackups: LogMaxBackups.Get(),
+			MaxAge:     LogMaxAge.Get(),
+			Compress:   LogCompress.Get(),
+		},
+	}
+}
+
+var (
+	// gLock 保护全局 viper，重载配置时持有写锁，保证读取方看到完整的一份配置
+	gLock sync.RWMutex
+	// gContent 为当前生效的配置文件内容，重载失败时用于回滚
+	gContent []byte
+
+	// envKeyReplacer 使 log.level 可以通过环境变量 LOG_LEVEL 设置
+	envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
 )
 
 func Init(path string, defaultConfig string) {
 	if !fileutil.Exist(path) {
+		log.Warn("config not found, write default config", "path", path)
 		err := fileutil.MkDir(filepath.Dir(path))
 		errutil.Check(err)
 		err = fileutil.Write(path, []byte(defaultConfig))
 		errutil.Check(err)
 	}
 
+	content, err := fileutil.Read(path)
+	errutil.Check(err)
+
+	gLock.Lock()
+	defer gLock.Unlock()
 	viper.SetConfigFile(path)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv()
-	err := viper.ReadInConfig()
+	err = viper.ReadConfig(bytes.NewReader(content))
 	errutil.Check(err)
+	gContent = content
 }
@@ -0,0 +1,197 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+var (
+	tomlTableRe = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+	tomlKeyRe   = regexp.MustCompile(`^(\s*)([A-Za-z0-9_\-.]+)(\s*=\s*)(.*)$`)
+)
+
+// EncodeValue 将命令行输入转换为 TOML 值，本身是合法 TOML 值（数字、布尔、数组等）时原样使用，
+// 否则按字符串处理
+func EncodeValue(s string, forceString bool) (string, error) {
+	if !forceString && isTomlValue(s) {
+		return s, nil
+	}
+	b, err := toml.Marshal(map[string]string{"v": s})
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(b), "v = ")), nil
+}
+
+// SetInFile 修改 TOML 文件中 key 的值，保留其余内容与注释。value 需为编码后的 TOML 值
+func SetInFile(path, key, value string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	out, err := setInToml(string(content), key, value)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.WriteString(out)
+	if err == nil {
+		err = tmp.Chmod(stat.Mode())
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+func setInToml(content, key, value string) (string, error) {
+	lines := strings.Split(content, "\n")
+	table, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		table, name = key[:i], key[i+1:]
+	}
+
+	curTable := ""
+	tableFound := table == ""
+	// 第一个表头之前为根表
+	insertAt := len(lines)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			if curTable == table && insertAt == len(lines) && tableFound {
+				insertAt = lastContentLine(lines, i) + 1
+			}
+			curTable = "[["
+			if m := tomlTableRe.FindStringSubmatch(line); m != nil {
+				curTable = normalizeKey(m[1])
+				if curTable == table {
+					tableFound = true
+				}
+			}
+			continue
+		}
+		m := tomlKeyRe.FindStringSubmatch(line)
+		if m == nil || joinKey(curTable, normalizeKey(m[2])) != key {
+			continue
+		}
+
+		end, comment, ok := valueExtent(lines, i, m[4])
+		if !ok {
+			return "", errutil.WrapF("parse value of %s failed", key)
+		}
+		newLine := m[1] + m[2] + m[3] + value
+		if comment != "" {
+			newLine += " " + comment
+		}
+		lines = append(lines[:i], append([]string{newLine}, lines[end+1:]...)...)
+		return checkToml(strings.Join(lines, "\n"))
+	}
+
+	newLine := name + " = " + value
+	switch {
+	case tableFound && curTable == table && insertAt == len(lines):
+		// 目标表是最后一个表
+		insertAt = lastContentLine(lines, len(lines)) + 1
+	case !tableFound:
+		lines = append(trimTrailingEmpty(lines), "", "["+table+"]", newLine, "")
+		return checkToml(strings.Join(lines, "\n"))
+	}
+	lines = append(lines[:insertAt], append([]string{newLine}, lines[insertAt:]...)...)
+	return checkToml(strings.Join(lines, "\n"))
+}
+
+// valueExtent 找出从第 start 行开始的值占用的最后一行，以及该行末尾的注释
+func valueExtent(lines []string, start int, first string) (int, string, bool) {
+	value := first
+	for end := start; end < len(lines); end++ {
+		if end > start {
+			value += "\n" + lines[end]
+		}
+		if !isTomlValue(value) {
+			continue
+		}
+		// 从左到右尝试在 # 处截断，能单独解析的部分即为值，剩余为注释
+		for j := strings.Index(value, "#"); j >= 0; {
+			if isTomlValue(value[:j]) && !strings.Contains(value[j:], "\n") {
+				return end, strings.TrimSpace(value[j:]), true
+			}
+			next := strings.Index(value[j+1:], "#")
+			if next < 0 {
+				break
+			}
+			j += next + 1
+		}
+		return end, "", true
+	}
+	return 0, "", false
+}
+
+func isTomlValue(s string) bool {
+	if strings.TrimSpace(s) == "" {
+		return false
+	}
+	var m map[string]any
+	return toml.Unmarshal([]byte("v = "+s), &m) == nil
+}
+
+func checkToml(content string) (string, error) {
+	var m map[string]any
+	err := toml.Unmarshal([]byte(content), &m)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	return content, nil
+}
+
+func normalizeKey(k string) string {
+	parts := strings.Split(k, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+func joinKey(table, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+func lastContentLine(lines []string, before int) int {
+	for i := before - 1; i >= 0; i-- {
+		trim := strings.TrimSpace(lines[i])
+		if trim != "" && !strings.HasPrefix(trim, "#") {
+			return i
+		}
+	}
+	return before - 1
+}
+
+func trimTrailingEmpty(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
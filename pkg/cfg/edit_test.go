@@ -0,0 +1,67 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const editToml = `# top comment
+name = "vkiss"
+
+[log]
+level = "info" # log level
+path = ""
+
+# ddns config
+[ddns]
+listen = ":5801"
+hosts = [
+    "a",
+    "b",
+]
+
+[ddns.tencent_cloud]
+secret_id = ""
+`
+
+func TestSetInToml(t *testing.T) {
+	cases := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"log.level", `"debug"`, `level = "debug" # log level`},
+		{"name", `"new"`, `name = "new"`},
+		{"ddns.hosts", `["c"]`, "listen = \":5801\"\nhosts = [\"c\"]\n\n[ddns.tencent_cloud]"},
+		{"ddns.tencent_cloud.secret_id", `"id#1"`, `secret_id = "id#1"`},
+		{"ddns.interval", `"10m"`, "hosts = [\n    \"a\",\n    \"b\",\n]\ninterval = \"10m\"\n\n[ddns.tencent_cloud]"},
+		{"log.max_size", `100`, "path = \"\"\nmax_size = 100\n\n# ddns config"},
+		{"version", `1`, "name = \"vkiss\"\nversion = 1\n\n[log]"},
+		{"ddns.cloudflare.token", `"x"`, "secret_id = \"\"\n\n[ddns.cloudflare]\ntoken = \"x\"\n"},
+	}
+	for _, c := range cases {
+		out, err := setInToml(editToml, c.key, c.value)
+		assert.Nil(t, err, c.key)
+		assert.Contains(t, out, c.want, c.key)
+		assert.Contains(t, out, "# top comment", c.key)
+		assert.Contains(t, out, "# ddns config", c.key)
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	for in, want := range map[string]string{
+		"123":     "123",
+		"true":    "true",
+		"[1, 2]":  "[1, 2]",
+		"1.2.3.4": `'1.2.3.4'`,
+		"20m":     `'20m'`,
+	} {
+		v, err := EncodeValue(in, false)
+		assert.Nil(t, err)
+		assert.Equal(t, want, v, in)
+	}
+	v, err := EncodeValue("123", true)
+	assert.Nil(t, err)
+	assert.Equal(t, `'123'`, v)
+}
@@ -2,19 +2,35 @@ package cfg
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
 	"github.com/vksir/vkiss-lib/pkg/util/errutil"
-	"reflect"
 )
 
 // flagType 在添加更多类型前，需要在 Flag.Bind 和 Flag.Get 添加对应 case
+//
+// 其他类型可通过 NewValueFlag 接入，只要求其指针实现 pflag.Value
 type flagType interface {
-	string | bool | int | uint | int64 | uint64
+	string | bool | int | uint | int64 | uint64 | float64 |
+		time.Duration | []string | []int | map[string]string |
+		net.IP | netip.Prefix | url.URL
+}
+
+// valueType 约束 *T 实现 pflag.Value，用于 NewValueFlag
+type valueType[T any] interface {
+	*T
+	pflag.Value
 }
 
-type Flag[T flagType] struct {
+type Flag[T any] struct {
 	Name       string
 	Short      string
 	Usage      string
@@ -22,10 +38,29 @@ type Flag[T flagType] struct {
 	Cfg        string
 	Persistent bool
 	Required   bool
+	// Validate 为 go-playground/validator 的校验规则，如 "required,hostname"
+	Validate string
+	// Secret 标记敏感配置，其值不会以明文出现在日志与配置输出中
+	Secret bool
+
+	cmds []*cobra.Command
 }
 
 func NewFlag[T flagType](name, cfg, usage string) *Flag[T] {
-	return &Flag[T]{Name: name, Cfg: cfg, Usage: usage}
+	return newFlag[T](name, cfg, usage)
+}
+
+// NewValueFlag 创建自定义类型的 Flag，*T 需实现 pflag.Value。
+// 从配置文件读取时，会将配置值转为字符串后交给 pflag.Value.Set 解析，
+// 因此 String 的输出需要能被 Set 重新解析
+func NewValueFlag[T any, PT valueType[T]](name, cfg, usage string) *Flag[T] {
+	return newFlag[T](name, cfg, usage)
+}
+
+func newFlag[T any](name, cfg, usage string) *Flag[T] {
+	f := &Flag[T]{Name: name, Cfg: cfg, Usage: usage}
+	gFlags = append(gFlags, f)
+	return f
 }
 
 func (f *Flag[T]) SetShort(v string) *Flag[T] {
@@ -48,6 +83,11 @@ func (f *Flag[T]) SetRequired(v bool) *Flag[T] {
 	return f
 }
 
+func (f *Flag[T]) SetSecret(v bool) *Flag[T] {
+	f.Secret = v
+	return f
+}
+
 func (f *Flag[T]) Bind(cmd *cobra.Command) {
 	var flagSet *pflag.FlagSet
 	if f.Persistent {
@@ -59,7 +99,7 @@ func (f *Flag[T]) Bind(cmd *cobra.Command) {
 	def := reflect.ValueOf(f.Default).Interface()
 
 	var t T
-	switch reflect.ValueOf(t).Interface().(type) {
+	switch reflect.ValueOf(&t).Elem().Interface().(type) {
 	case string:
 		flagSet.StringP(f.Name, f.Short, def.(string), f.Usage)
 	case bool:
@@ -72,8 +112,33 @@ func (f *Flag[T]) Bind(cmd *cobra.Command) {
 		flagSet.Int64P(f.Name, f.Short, def.(int64), f.Usage)
 	case uint64:
 		flagSet.Uint64P(f.Name, f.Short, def.(uint64), f.Usage)
+	case float64:
+		flagSet.Float64P(f.Name, f.Short, def.(float64), f.Usage)
+	case time.Duration:
+		flagSet.DurationP(f.Name, f.Short, def.(time.Duration), f.Usage)
+	case []string:
+		flagSet.StringSliceP(f.Name, f.Short, def.([]string), f.Usage)
+	case []int:
+		flagSet.IntSliceP(f.Name, f.Short, def.([]int), f.Usage)
+	case map[string]string:
+		flagSet.StringToStringP(f.Name, f.Short, def.(map[string]string), f.Usage)
+	case net.IP:
+		flagSet.IPP(f.Name, f.Short, def.(net.IP), f.Usage)
+	case netip.Prefix:
+		p := def.(netip.Prefix)
+		flagSet.VarP((*prefixValue)(&p), f.Name, f.Short, f.Usage)
+	case url.URL:
+		u := def.(url.URL)
+		flagSet.VarP((*urlValue)(&u), f.Name, f.Short, f.Usage)
 	default:
-		errutil.Check(fmt.Errorf("type %T not supported", t))
+		// 拷贝一份默认值，避免命令行解析时改写 f.Default
+		v := new(T)
+		*v = f.Default
+		value, ok := any(v).(pflag.Value)
+		if !ok {
+			errutil.Check(fmt.Errorf("type %T not supported", t))
+		}
+		flagSet.VarP(value, f.Name, f.Short, f.Usage)
 	}
 
 	if f.Required {
@@ -83,15 +148,32 @@ func (f *Flag[T]) Bind(cmd *cobra.Command) {
 
 	err := viper.BindPFlag(f.Cfg, flagSet.Lookup(f.Name))
 	errutil.Check(err)
+	f.cmds = append(f.cmds, cmd)
 }
 
+// Get 返回配置值，配置无法解析时返回零值并记录日志，具体原因由 Validate 报告
 func (f *Flag[T]) Get() T {
+	gLock.RLock()
+	defer gLock.RUnlock()
+	return f.get()
+}
+
+func (f *Flag[T]) get() T {
+	v, err := f.value()
+	if err != nil {
+		log.Error("get config failed", "key", f.Cfg, "err", err)
+	}
+	return v
+}
+
+// value 读取配置值，解析失败时返回零值与错误
+func (f *Flag[T]) value() (T, error) {
 	var v any
 
 	var t T
-	switch reflect.ValueOf(t).Interface().(type) {
+	switch reflect.ValueOf(&t).Elem().Interface().(type) {
 	case string:
-		v = viper.GetString(f.Cfg)
+		v = f.getString()
 	case bool:
 		v = viper.GetBool(f.Cfg)
 	case int:
@@ -102,9 +184,75 @@ func (f *Flag[T]) Get() T {
 		v = viper.GetInt64(f.Cfg)
 	case uint64:
 		v = viper.GetUint64(f.Cfg)
+	case float64:
+		v = viper.GetFloat64(f.Cfg)
+	case time.Duration:
+		v = viper.GetDuration(f.Cfg)
+	case []string:
+		v = viper.GetStringSlice(f.Cfg)
+	case []int:
+		v = viper.GetIntSlice(f.Cfg)
+	case map[string]string:
+		v = viper.GetStringMapString(f.Cfg)
+	case net.IP:
+		v = net.ParseIP(viper.GetString(f.Cfg))
+	case netip.Prefix:
+		var p prefixValue
+		if err := f.parse(&p); err != nil {
+			return t, err
+		}
+		v = netip.Prefix(p)
+	case url.URL:
+		var u urlValue
+		if err := f.parse(&u); err != nil {
+			return t, err
+		}
+		v = url.URL(u)
 	default:
-		errutil.Check(fmt.Errorf("type %T not supported", t))
+		value, ok := any(&t).(pflag.Value)
+		if !ok {
+			return t, fmt.Errorf("type %T not supported", t)
+		}
+		if err := f.parse(value); err != nil {
+			var zero T
+			return zero, err
+		}
+		v = t
+	}
+
+	return v.(T), nil
+}
+
+// parse 将配置值交给 pflag.Value 解析，配置为空时保持零值
+func (f *Flag[T]) parse(value pflag.Value) error {
+	s := viper.GetString(f.Cfg)
+	if s == "" {
+		return nil
 	}
+	return value.Set(s)
+}
 
-	return v.(T)
+// getString 读取字符串配置并解析其中的敏感信息引用，解析失败时返回空值，
+// 由 Validate 报告具体原因
+func (f *Flag[T]) getString() string {
+	v, err := ResolveSecret(viper.GetString(f.Cfg))
+	if err != nil {
+		log.Error("resolve secret failed", "key", f.Cfg, "err", err)
+		return ""
+	}
+	if f.Secret {
+		log.AddSecret(v)
+	}
+	return v
+}
+
+func (f *Flag[T]) names() (string, string) {
+	return f.Name, f.Cfg
+}
+
+func (f *Flag[T]) secretKey() string {
+	if f.Secret {
+		return f.Cfg
+	}
+	return ""
 }
@@ -1,10 +1,15 @@
 package cfg
 
 import (
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestFlag(t *testing.T) {
@@ -25,3 +30,81 @@ func TestFlag(t *testing.T) {
 	assert.Equal(t, f3.Get(), true)
 	assert.Equal(t, f4.Get(), false)
 }
+
+func TestFlagMoreTypes(t *testing.T) {
+	cmd := &cobra.Command{Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.SetArgs([]string{
+		"--duration", "90s",
+		"--float", "1.5",
+		"--strings", "a,b",
+		"--ints", "1,2",
+		"--map", "k1=v1,k2=v2",
+		"--ip", "192.168.1.1",
+		"--prefix", "10.0.0.0/8",
+		"--url", "http://127.0.0.1:5801/my_ip",
+	})
+	f1 := NewFlag[time.Duration]("duration", "more.duration", "")
+	f2 := NewFlag[float64]("float", "more.float", "")
+	f3 := NewFlag[[]string]("strings", "more.strings", "")
+	f4 := NewFlag[[]int]("ints", "more.ints", "")
+	f5 := NewFlag[map[string]string]("map", "more.map", "")
+	f6 := NewFlag[net.IP]("ip", "more.ip", "")
+	f7 := NewFlag[netip.Prefix]("prefix", "more.prefix", "")
+	f8 := NewFlag[url.URL]("url", "more.url", "")
+	f9 := NewFlag[time.Duration]("duration-default", "more.duration_default", "").
+		SetDefault(time.Minute)
+	for _, f := range []interface{ Bind(*cobra.Command) }{f1, f2, f3, f4, f5, f6, f7, f8, f9} {
+		f.Bind(cmd)
+	}
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, f1.Get())
+	assert.Equal(t, 1.5, f2.Get())
+	assert.Equal(t, []string{"a", "b"}, f3.Get())
+	assert.Equal(t, []int{1, 2}, f4.Get())
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, f5.Get())
+	assert.Equal(t, "192.168.1.1", f6.Get().String())
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), f7.Get())
+	u := f8.Get()
+	assert.Equal(t, "127.0.0.1:5801", u.Host)
+	assert.Equal(t, time.Minute, f9.Get())
+}
+
+type level int
+
+func (l *level) String() string {
+	switch *l {
+	case 1:
+		return "low"
+	case 2:
+		return "high"
+	default:
+		return ""
+	}
+}
+
+func (l *level) Set(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("invalid level: %s", s)
+	}
+	return nil
+}
+
+func (l *level) Type() string {
+	return "level"
+}
+
+func TestValueFlag(t *testing.T) {
+	cmd := &cobra.Command{Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.SetArgs([]string{"--level", "high"})
+	f := NewValueFlag[level]("level", "value.level", "")
+	f.Bind(cmd)
+	err := cmd.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, level(2), f.Get())
+}
@@ -0,0 +1,206 @@
+package cfg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
+)
+
+// 配置值支持以下形式的敏感信息引用：
+//
+//	env:TC_SECRET_KEY          读取环境变量
+//	file:/run/secrets/tc_key   读取文件内容，去掉首尾空白
+//	enc:BASE64                 使用 secret.key_file 中的密钥以 AES-GCM 解密
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+	secretEncPrefix  = "enc:"
+)
+
+var SecretKeyFile = NewFlag[string]("secret-key-file", "secret.key_file",
+	"key file for enc: config values").SetDefault("/etc/vkiss/secret.key").SetPersistent(true)
+
+// IsSecretRef 判断配置值是否为敏感信息引用
+func IsSecretRef(s string) bool {
+	return strings.HasPrefix(s, secretEnvPrefix) ||
+		strings.HasPrefix(s, secretFilePrefix) ||
+		strings.HasPrefix(s, secretEncPrefix)
+}
+
+// ResolveSecret 解析敏感信息引用，非引用的值原样返回。
+// 解析出的值会登记到 log，之后不会以明文出现在日志中
+func ResolveSecret(s string) (string, error) {
+	var v string
+	switch {
+	case strings.HasPrefix(s, secretEnvPrefix):
+		name := strings.TrimPrefix(s, secretEnvPrefix)
+		var ok bool
+		v, ok = os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("env %s not set", name)
+		}
+	case strings.HasPrefix(s, secretFilePrefix):
+		content, err := fileutil.Read(strings.TrimPrefix(s, secretFilePrefix))
+		if err != nil {
+			return "", errutil.Wrap(err)
+		}
+		v = strings.TrimSpace(string(content))
+	case strings.HasPrefix(s, secretEncPrefix):
+		key, err := readSecretKey()
+		if err != nil {
+			return "", err
+		}
+		v, err = Decrypt(key, strings.TrimPrefix(s, secretEncPrefix))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return s, nil
+	}
+	log.AddSecret(v)
+	return v, nil
+}
+
+// EncryptSecret 使用 secret.key_file 中的密钥加密，返回可直接写入配置的 enc: 值
+func EncryptSecret(plaintext string) (string, error) {
+	key, err := readSecretKey()
+	if err != nil {
+		return "", err
+	}
+	v, err := Encrypt(key, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return secretEncPrefix + v, nil
+}
+
+// GenerateSecretKey 生成 32 字节的随机密钥，以 base64 写入 path
+func GenerateSecretKey(path string) error {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	err = os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+// Encrypt 使用 AES-GCM 加密，返回 base64(nonce+ciphertext)
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func Decrypt(key []byte, s string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errutil.WrapF("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errutil.Wrap(err)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	return gcm, nil
+}
+
+// readSecretKey 读取密钥文件，内容为 base64 编码或原始的 16/24/32 字节
+func readSecretKey() ([]byte, error) {
+	path := viper.GetString(SecretKeyFile.Cfg)
+	content, err := fileutil.Read(path)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		key = content
+	}
+	return key, nil
+}
+
+var gSecretKeys []string
+
+// MarkSecret 将不通过 Flag 读取的配置 key 标记为敏感，如 UnmarshalKey 读取的结构体字段。
+// key 中的 "*" 匹配任意一段，如 "ddns.accounts.*.api_token"。
+// 这些值在 Settings 中脱敏，并在 UnmarshalKey 时加入日志脱敏
+func MarkSecret(keys ...string) {
+	gSecretKeys = append(gSecretKeys, keys...)
+}
+
+// isSecretKey 判断 key 是否被 MarkSecret 标记
+func isSecretKey(key string) bool {
+	parts := strings.Split(key, ".")
+	for _, pattern := range gSecretKeys {
+		patterns := strings.Split(pattern, ".")
+		if len(patterns) != len(parts) {
+			continue
+		}
+		matched := true
+		for i, p := range patterns {
+			if p != "*" && p != parts[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretKeys 返回所有标记为敏感的配置 key，MarkSecret 的通配按当前配置展开
+func SecretKeys() []string {
+	var keys []string
+	for _, key := range viper.AllKeys() {
+		if isSecretKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, f := range gFlags {
+		if key := f.secretKey(); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
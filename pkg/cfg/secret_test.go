@@ -0,0 +1,86 @@
+package cfg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("VKISS_TEST_SECRET", "env-secret")
+	v, err := ResolveSecret("env:VKISS_TEST_SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "env-secret", v)
+	_, err = ResolveSecret("env:VKISS_TEST_NOT_SET")
+	assert.NotNil(t, err)
+
+	path := filepath.Join(dir, "secret")
+	assert.Nil(t, fileutil.Write(path, []byte("file-secret\n")))
+	v, err = ResolveSecret("file:" + path)
+	assert.Nil(t, err)
+	assert.Equal(t, "file-secret", v)
+
+	keyFile := filepath.Join(dir, "secret.key")
+	assert.Nil(t, GenerateSecretKey(keyFile))
+	viper.Set(SecretKeyFile.Cfg, keyFile)
+	enc, err := EncryptSecret("enc-secret")
+	assert.Nil(t, err)
+	v, err = ResolveSecret(enc)
+	assert.Nil(t, err)
+	assert.Equal(t, "enc-secret", v)
+
+	v, err = ResolveSecret("plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", v)
+
+	assert.Equal(t, "token=******", log.Redact("token=env-secret"))
+}
+
+func TestSettingsRedact(t *testing.T) {
+	t.Setenv("VKISS_TEST_TOKEN", "ref-token")
+	f1 := NewFlag[string]("redact-key", "redact.key", "").SetSecret(true)
+	f2 := NewFlag[string]("redact-ref", "redact.ref", "").SetSecret(true)
+	viper.Set("redact.key", "plain-key")
+	viper.Set("redact.ref", "env:VKISS_TEST_TOKEN")
+	viper.Set("redact.echo", "ref-token")
+	assert.Equal(t, "plain-key", f1.Get())
+	assert.Equal(t, "ref-token", f2.Get())
+
+	settings := make(map[string]any)
+	for _, s := range Settings(nil) {
+		settings[s.Key] = s.Value
+	}
+	assert.Equal(t, "******", settings["redact.key"])
+	assert.Equal(t, "env:VKISS_TEST_TOKEN", settings["redact.ref"])
+	assert.Equal(t, "******", settings["redact.echo"])
+}
+
+func TestMarkSecret(t *testing.T) {
+	MarkSecret("marked.token", "marked.accounts.*.key")
+	viper.Set("marked.token", "marked-token")
+	viper.Set("marked.name", "name")
+	viper.Set("marked.accounts.a.key", "account-key")
+
+	var c struct {
+		Token string `mapstructure:"token"`
+		Name  string `mapstructure:"name"`
+	}
+	assert.NoError(t, UnmarshalKey("marked", &c))
+	assert.Equal(t, "marked-token", c.Token)
+	assert.Equal(t, "token=******", log.Redact("token=marked-token"))
+	assert.Equal(t, "key=******", log.Redact("key=account-key"))
+
+	settings := make(map[string]any)
+	for _, s := range Settings(nil) {
+		settings[s.Key] = s.Value
+	}
+	assert.Equal(t, "******", settings["marked.token"])
+	assert.Equal(t, "******", settings["marked.accounts.a.key"])
+	assert.Equal(t, "name", settings["marked.name"])
+}
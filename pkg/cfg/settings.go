@@ -0,0 +1,91 @@
+package cfg
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+const redactedValue = "******"
+
+// Setting 为单个配置项的生效值及其来源
+type Setting struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// Settings 返回所有配置项的生效值，按 key 排序，敏感值已脱敏。
+// cmd 用于判断哪些值来自命令行
+func Settings(cmd *cobra.Command) []Setting {
+	gLock.RLock()
+	defer gLock.RUnlock()
+
+	secrets := make(map[string]bool)
+	for _, key := range SecretKeys() {
+		secrets[key] = true
+	}
+
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		v := viper.Get(key)
+		if s, ok := v.(string); ok {
+			if secrets[key] && s != "" && !IsSecretRef(s) {
+				v = redactedValue
+			} else {
+				v = log.Redact(s)
+			}
+		}
+		settings = append(settings, Setting{Key: key, Value: v, Source: source(cmd, key)})
+	}
+	return settings
+}
+
+// Lookup 返回单个配置项的生效值，规则与 Settings 相同
+func Lookup(cmd *cobra.Command, key string) (Setting, bool) {
+	for _, s := range Settings(cmd) {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+func source(cmd *cobra.Command, key string) string {
+	if cmd != nil {
+		for _, f := range gFlags {
+			name, cfg := f.names()
+			if cfg != key {
+				continue
+			}
+			if fl := cmd.Flags().Lookup(name); fl != nil && fl.Changed {
+				return SourceFlag
+			}
+		}
+	}
+	if _, ok := os.LookupEnv(envKey(key)); ok {
+		return SourceEnv
+	}
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// envKey 返回 viper.AutomaticEnv 对应的环境变量名
+func envKey(key string) string {
+	return strings.ToUpper(envKeyReplacer.Replace(key))
+}
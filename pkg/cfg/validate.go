@@ -0,0 +1,251 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/validutil"
+)
+
+var validate = func() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// 结构体字段使用配置中的 key 命名，与 viper.Unmarshal 保持一致
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return v
+}()
+
+// validatable 为不同类型参数的 Flag 提供统一的校验入口
+type validatable interface {
+	boundTo(cmd *cobra.Command) bool
+	validate() *FieldError
+	names() (string, string)
+	secretKey() string
+}
+
+var gFlags []validatable
+
+// FieldError 与 ValidationError 定义在无依赖的 validutil 中，供 apiutil 等使用而不引入配置相关的依赖
+type (
+	FieldError      = validutil.FieldError
+	ValidationError = validutil.ValidationError
+)
+
+// Describe 将 validator 的 tag 转换为可读描述
+//
+// Deprecated: 使用 validutil.Describe
+func Describe(tag, param string) string {
+	return validutil.Describe(tag, param)
+}
+
+func (f *Flag[T]) SetValidate(tag string) *Flag[T] {
+	f.Validate = tag
+	return f
+}
+
+func (f *Flag[T]) boundTo(cmd *cobra.Command) bool {
+	for _, c := range f.cmds {
+		if c == cmd {
+			return true
+		}
+		if f.Persistent {
+			for p := cmd.Parent(); p != nil; p = p.Parent() {
+				if p == c {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (f *Flag[T]) validate() *FieldError {
+	if raw := viper.GetString(f.Cfg); IsSecretRef(raw) {
+		if _, err := ResolveSecret(raw); err != nil {
+			return &FieldError{Key: f.Cfg, Flag: f.Name, Tag: "secret", Param: err.Error()}
+		}
+	}
+	val, err := f.value()
+	if err != nil {
+		return &FieldError{Key: f.Cfg, Flag: f.Name, Tag: "parse", Param: err.Error(), Value: viper.GetString(f.Cfg)}
+	}
+	if f.Validate == "" {
+		return nil
+	}
+	v := any(val)
+	// 网络相关类型以字符串形式校验，便于使用 ip、cidr、url 等规则
+	switch val := v.(type) {
+	case net.IP:
+		v = val.String()
+	case netip.Prefix:
+		v = val.String()
+	case url.URL:
+		v = val.String()
+	}
+	err = validate.Var(v, f.Validate)
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		return &FieldError{Key: f.Cfg, Flag: f.Name, Tag: errs[0].Tag(), Param: errs[0].Param(), Value: v}
+	}
+	if err != nil {
+		return &FieldError{Key: f.Cfg, Flag: f.Name, Tag: "validate", Param: err.Error(), Value: v}
+	}
+	return nil
+}
+
+// structLoader 为 RegisterStruct 注册的结构体配置
+type structLoader struct {
+	cmd  *cobra.Command
+	key  string
+	load func() error
+}
+
+var gStructs []structLoader
+
+// RegisterStruct 注册 cmd 通过 UnmarshalKey 读取的结构体配置，Validate(cmd) 时调用 load 解析并校验，
+// 使 config validate 与启动时的检查一致。load 返回的 ValidationError 合并到结果中，
+// 其他错误归于 key
+func RegisterStruct(cmd *cobra.Command, key string, load func() error) {
+	gStructs = append(gStructs, structLoader{cmd: cmd, key: key, load: load})
+}
+
+// Validate 校验 cmd 上绑定的所有 Flag 与注册的结构体配置，返回汇总的 ValidationError
+func Validate(cmd *cobra.Command) error {
+	gLock.RLock()
+	err := validateFlags(cmd)
+	gLock.RUnlock()
+	// Flag 无效时结构体配置的加载结果没有意义，只报告 Flag 的错误
+	if err != nil {
+		return err
+	}
+
+	// load 内部通过 UnmarshalKey 与 Flag.Get 获取读锁，不能在持有锁时调用
+	var fields []*FieldError
+	for _, s := range gStructs {
+		if s.cmd != cmd {
+			continue
+		}
+		err := s.load()
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			fields = append(fields, verr.Fields...)
+		} else if err != nil {
+			fields = append(fields, &FieldError{Key: s.key, Tag: "load", Param: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validateFlags(cmd *cobra.Command) error {
+	var fields []*FieldError
+	for _, f := range gFlags {
+		if !f.boundTo(cmd) {
+			continue
+		}
+		if fe := f.validate(); fe != nil {
+			fields = append(fields, fe)
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// ValidateStruct 按 validate tag 校验配置结构体，key 为其在配置中的前缀，
+// 结构体切片逐项校验，key 形如 "ddns.records[0]"
+func ValidateStruct(key string, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return validateStruct(key, v)
+	}
+	var fields []*FieldError
+	for i := 0; i < rv.Len(); i++ {
+		err := validateStruct(fmt.Sprintf("%s[%d]", key, i), rv.Index(i).Interface())
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			fields = append(fields, verr.Fields...)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validateStruct(key string, v any) error {
+	err := validate.Struct(v)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	fields := make([]*FieldError, 0, len(errs))
+	for _, e := range errs {
+		// 去掉 Namespace 中的结构体类型名
+		_, name, _ := strings.Cut(e.Namespace(), ".")
+		if key != "" {
+			name = key + "." + name
+		}
+		fields = append(fields, &FieldError{Key: name, Tag: e.Tag(), Param: e.Param(), Value: e.Value()})
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// UnmarshalKey 将 key 下的配置解析到结构体并校验
+func UnmarshalKey(key string, v any) error {
+	gLock.RLock()
+	err := viper.UnmarshalKey(key, v, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		resolveSecretHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+	if err == nil {
+		for _, k := range viper.AllKeys() {
+			if !strings.HasPrefix(k, key+".") || !isSecretKey(k) {
+				continue
+			}
+			// 引用的值已在解析时加入
+			if s := viper.GetString(k); !IsSecretRef(s) {
+				log.AddSecret(s)
+			}
+		}
+	}
+	gLock.RUnlock()
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	return ValidateStruct(key, v)
+}
+
+// resolveSecretHook 在解析结构体时处理字符串中的敏感信息引用
+func resolveSecretHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	s, ok := data.(string)
+	if !ok || from.Kind() != reflect.String {
+		return data, nil
+	}
+	return ResolveSecret(s)
+}
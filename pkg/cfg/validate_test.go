@@ -0,0 +1,100 @@
+package cfg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	root := &cobra.Command{}
+	sub := &cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}}
+	other := &cobra.Command{Use: "other", Run: func(cmd *cobra.Command, args []string) {}}
+	root.AddCommand(sub, other)
+
+	f1 := NewFlag[string]("host", "validate.host", "").SetValidate("required,hostname")
+	f2 := NewFlag[int]("port", "validate.port", "").SetDefault(80).SetValidate("min=1,max=65535")
+	f3 := NewFlag[string]("mode", "validate.mode", "").SetValidate("oneof=a b").SetPersistent(true)
+	f4 := NewFlag[string]("secret", "validate.secret", "").SetValidate("required")
+	f1.Bind(sub)
+	f2.Bind(sub)
+	f3.Bind(root)
+	f4.Bind(other)
+
+	root.SetArgs([]string{"sub", "--port", "70000", "--mode", "c"})
+	assert.Nil(t, root.Execute())
+
+	err := Validate(sub)
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Fields, 3)
+	assert.Equal(t, "invalid config:\n"+
+		"  validate.host (--host) is required\n"+
+		"  validate.port (--port) must be at most 65535\n"+
+		"  validate.mode (--mode) must be one of [a, b]", err.Error())
+
+	viper.Set("validate.host", "example.com")
+	viper.Set("validate.port", 8080)
+	viper.Set("validate.mode", "a")
+	assert.Nil(t, Validate(sub))
+}
+
+func TestUnmarshalKey(t *testing.T) {
+	type Account struct {
+		SecretId string `mapstructure:"secret_id" validate:"required"`
+		Endpoint string `mapstructure:"endpoint" validate:"omitempty,url"`
+	}
+	viper.Set("unmarshal.account.endpoint", "not a url")
+
+	var a Account
+	err := UnmarshalKey("unmarshal.account", &a)
+	assert.Equal(t, "invalid config:\n"+
+		"  unmarshal.account.secret_id is required\n"+
+		"  unmarshal.account.endpoint must be a valid URL", err.Error())
+}
+
+func TestUnmarshalKeySlice(t *testing.T) {
+	type Record struct {
+		Domain string `mapstructure:"domain" validate:"required,fqdn"`
+		TTL    int    `mapstructure:"ttl" validate:"min=0"`
+	}
+	viper.Set("unmarshal.records", []map[string]any{
+		{"domain": "example.com", "ttl": 600},
+		{"ttl": -1},
+	})
+
+	var rs []Record
+	err := UnmarshalKey("unmarshal.records", &rs)
+	assert.Equal(t, "invalid config:\n"+
+		"  unmarshal.records[1].domain is required\n"+
+		"  unmarshal.records[1].ttl must be at least 0", err.Error())
+	assert.Len(t, rs, 2)
+	assert.Equal(t, 600, rs[0].TTL)
+}
+
+func TestRegisterStruct(t *testing.T) {
+	type Record struct {
+		Domain string `mapstructure:"domain" validate:"required,fqdn"`
+	}
+	cmd := &cobra.Command{Use: "struct"}
+	other := &cobra.Command{Use: "other"}
+	RegisterStruct(cmd, "struct.records", func() error {
+		var rs []Record
+		return UnmarshalKey("struct.records", &rs)
+	})
+	RegisterStruct(cmd, "struct.accounts", func() error {
+		return errors.New("unknown account")
+	})
+	RegisterStruct(other, "struct.other", func() error {
+		return errors.New("not checked")
+	})
+	viper.Set("struct.records", []map[string]any{{"domain": "example.com"}, {}})
+
+	err := Validate(cmd)
+	assert.Equal(t, "invalid config:\n"+
+		"  struct.records[1].domain is required\n"+
+		"  struct.accounts is invalid: unknown account", err.Error())
+}
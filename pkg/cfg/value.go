@@ -0,0 +1,51 @@
+package cfg
+
+import (
+	"net/netip"
+	"net/url"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// prefixValue 为 netip.Prefix 实现 pflag.Value
+type prefixValue netip.Prefix
+
+func (p *prefixValue) String() string {
+	if !netip.Prefix(*p).IsValid() {
+		return ""
+	}
+	return netip.Prefix(*p).String()
+}
+
+func (p *prefixValue) Set(s string) error {
+	v, err := netip.ParsePrefix(s)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	*p = prefixValue(v)
+	return nil
+}
+
+func (p *prefixValue) Type() string {
+	return "prefix"
+}
+
+// urlValue 为 url.URL 实现 pflag.Value
+type urlValue url.URL
+
+func (u *urlValue) String() string {
+	return (*url.URL)(u).String()
+}
+
+func (u *urlValue) Set(s string) error {
+	v, err := url.Parse(s)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	*u = urlValue(*v)
+	return nil
+}
+
+func (u *urlValue) Type() string {
+	return "url"
+}
@@ -0,0 +1,178 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
+)
+
+// ReloadDebounce 为配置文件变化后等待的时间，合并编辑器保存时产生的多次事件
+var ReloadDebounce = 500 * time.Millisecond
+
+// ChangeFunc 在 key 对应的配置值发生变化时被调用
+type ChangeFunc func(key string, old, new any)
+
+var (
+	gChangeFuncs     = make(map[string][]ChangeFunc)
+	gChangeFuncsLock sync.Mutex
+)
+
+func init() {
+	// log.level 变化时直接作用于运行中的 logger，命令行指定的值仍然优先
+	OnChange(LogLevel.Cfg, func(key string, old, new any) {
+		level := LogLevel.Get()
+		err := log.SetLevel(level)
+		if err != nil {
+			log.Error("apply log level failed", "level", level, "err", err)
+			return
+		}
+		log.Warn("apply log level", "level", level)
+	})
+	OnChange(LogModules.Cfg, func(key string, old, new any) {
+		modules := LogModules.Get()
+		for _, m := range log.Modules() {
+			if _, ok := modules[m.Name]; !ok && m.Level != "" {
+				modules[m.Name] = ""
+			}
+		}
+		for name, level := range modules {
+			err := log.SetModuleLevel(name, level)
+			if err != nil {
+				log.Error("apply module log level failed", "module", name, "level", level, "err", err)
+				continue
+			}
+			log.Warn("apply module log level", "module", name, "level", level)
+		}
+	})
+}
+
+// OnChange 注册 key 的变更回调，key 也可以是 "ddns" 这样的前缀
+func OnChange(key string, f ChangeFunc) {
+	gChangeFuncsLock.Lock()
+	defer gChangeFuncsLock.Unlock()
+	gChangeFuncs[key] = append(gChangeFuncs[key], f)
+}
+
+// Watch 监听配置文件变化并重载，ctx 结束后退出。
+// 重载后会按 cmd 上绑定的 Flag 重新校验，校验失败则保留原配置
+func Watch(ctx context.Context, cmd *cobra.Command) error {
+	path := viper.ConfigFileUsed()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	// 监听目录而非文件，编辑器常以重命名的方式保存文件
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		_ = watcher.Close()
+		return errutil.Wrap(err)
+	}
+
+	logger := log.With("config", path)
+	logger.Info("begin watch config")
+	go func() {
+		defer log.Close(watcher)
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				logger.Info("exit watch config")
+				return
+			case err := <-watcher.Errors:
+				logger.Error("watch config failed", "err", err)
+			case e := <-watcher.Events:
+				if filepath.Clean(e.Name) != filepath.Clean(path) ||
+					!e.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(ReloadDebounce, func() {
+					err := Reload(cmd)
+					if err != nil {
+						logger.Error("reload config failed, keep old config", "err", err)
+					}
+				})
+			}
+		}
+	}()
+	return nil
+}
+
+// Reload 重新读取配置文件，校验失败时回滚并返回错误
+func Reload(cmd *cobra.Command) error {
+	path := viper.ConfigFileUsed()
+	content, err := fileutil.Read(path)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+
+	changes, err := swap(cmd, content)
+	if err != nil {
+		return err
+	}
+	log.Info("reload config success", "config", path, "changes", len(changes))
+
+	// 复制回调后在锁外调用，回调中可以再调用 OnChange
+	funcs := make(map[string][]ChangeFunc, len(changes))
+	gChangeFuncsLock.Lock()
+	for key := range changes {
+		funcs[key] = slices.Clone(gChangeFuncs[key])
+	}
+	gChangeFuncsLock.Unlock()
+	for key, fs := range funcs {
+		for _, f := range fs {
+			f(key, changes[key][0], changes[key][1])
+		}
+	}
+	return nil
+}
+
+// swap 在写锁内替换配置，返回发生变化的 key 及其新旧值
+func swap(cmd *cobra.Command, content []byte) (map[string][2]any, error) {
+	gLock.Lock()
+	defer gLock.Unlock()
+	if bytes.Equal(content, gContent) {
+		return nil, nil
+	}
+
+	gChangeFuncsLock.Lock()
+	old := make(map[string]any, len(gChangeFuncs))
+	for key := range gChangeFuncs {
+		old[key] = viper.Get(key)
+	}
+	gChangeFuncsLock.Unlock()
+
+	err := viper.ReadConfig(bytes.NewReader(content))
+	if err == nil && cmd != nil {
+		err = validateFlags(cmd)
+	}
+	if err != nil {
+		errutil.Check(viper.ReadConfig(bytes.NewReader(gContent)))
+		return nil, err
+	}
+	gContent = content
+
+	changes := make(map[string][2]any)
+	for key, v := range old {
+		if cur := viper.Get(key); !reflect.DeepEqual(v, cur) {
+			changes[key] = [2]any{v, cur}
+		}
+	}
+	return changes, nil
+}
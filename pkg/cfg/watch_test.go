@@ -0,0 +1,103 @@
+package cfg
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/util/fileutil"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	Init(path, "[watch]\nname = \"v1\"\nport = 80\n")
+
+	cmd := &cobra.Command{Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.SetArgs([]string{})
+	name := NewFlag[string]("watch-name", "watch.name", "")
+	port := NewFlag[int]("watch-port", "watch.port", "").SetValidate("max=65535")
+	name.Bind(cmd)
+	port.Bind(cmd)
+	assert.Nil(t, cmd.Execute())
+
+	changed := make(chan [2]any, 1)
+	OnChange("watch.name", func(key string, old, new any) {
+		changed <- [2]any{old, new}
+	})
+
+	ReloadDebounce = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(t, Watch(ctx, cmd))
+
+	assert.Nil(t, fileutil.Write(path, []byte("[watch]\nname = \"v2\"\nport = 80\n")))
+	select {
+	case c := <-changed:
+		assert.Equal(t, [2]any{"v1", "v2"}, c)
+	case <-time.After(3 * time.Second):
+		t.Fatal("change callback not called")
+	}
+	assert.Equal(t, "v2", name.Get())
+
+	// 校验失败的配置不生效
+	assert.Nil(t, fileutil.Write(path, []byte("[watch]\nname = \"v3\"\nport = 70000\n")))
+	assert.NotNil(t, Reload(cmd))
+	assert.Equal(t, "v2", name.Get())
+	assert.Equal(t, 80, port.Get())
+}
+
+func TestReloadParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	Init(path, "[parse]\nprefix = \"10.0.0.0/8\"\nlevel = \"low\"\n")
+
+	cmd := &cobra.Command{Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.SetArgs([]string{})
+	prefix := NewFlag[netip.Prefix]("parse-prefix", "parse.prefix", "")
+	lvl := NewValueFlag[level]("parse-level", "parse.level", "")
+	prefix.Bind(cmd)
+	lvl.Bind(cmd)
+	assert.Nil(t, cmd.Execute())
+
+	// 无法解析的值不会退出进程，而是作为校验失败回滚
+	assert.Nil(t, fileutil.Write(path, []byte("[parse]\nprefix = \"x\"\nlevel = \"middle\"\n")))
+	err := Reload(cmd)
+	var verr *ValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Fields, 2)
+	assert.Equal(t, "parse", verr.Fields[0].Tag)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), prefix.Get())
+	assert.Equal(t, level(1), lvl.Get())
+}
+
+func TestReloadOnChangeInCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	Init(path, "[nested]\nname = \"v1\"\n")
+
+	cmd := &cobra.Command{Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.SetArgs([]string{})
+	NewFlag[string]("nested-name", "nested.name", "").Bind(cmd)
+	assert.Nil(t, cmd.Execute())
+
+	// 回调中注册新的回调不会死锁
+	called := false
+	OnChange("nested.name", func(key string, old, new any) {
+		called = true
+		OnChange("nested.name", func(key string, old, new any) {})
+	})
+	assert.Nil(t, fileutil.Write(path, []byte("[nested]\nname = \"v2\"\n")))
+	done := make(chan error, 1)
+	go func() {
+		done <- Reload(cmd)
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("reload deadlocked")
+	}
+	assert.True(t, called)
+}
@@ -0,0 +1,284 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const HeaderRequestId = "X-Request-ID"
+
+// maxErrorBody 为错误信息中保留的响应体长度
+const maxErrorBody = 512
+
+type Config struct {
+	// BaseUrl 为请求路径的前缀，请求使用完整 URL 时忽略
+	BaseUrl string
+	// Timeout 为单次请求的超时，默认 10s
+	Timeout time.Duration
+	// RetryCount 为 5xx 与网络错误的重试次数，默认 2，小于 0 不重试。
+	// 默认只重试幂等的方法，可通过 WithRetry 逐个请求覆盖
+	RetryCount int
+	// RetryWait 与 RetryMaxWait 为指数退避的初始与最大等待时间，默认 500ms 与 5s
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	Headers      map[string]string
+	// Transport 为空时使用 http.DefaultTransport，测试时可替换
+	Transport http.RoundTripper
+	// Logger 为空时在每次记录时使用 log.Named("httpclient")，跟随默认 logger 的替换
+	Logger *log.Logger
+}
+
+// Client 为基于 resty 的 HTTP 客户端，带重试、日志与请求 ID 透传
+type Client struct {
+	client *resty.Client
+	logger *log.Logger
+}
+
+func New(c Config) *Client {
+	if c.Timeout == 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.RetryCount == 0 {
+		c.RetryCount = 2
+	}
+	if c.RetryWait == 0 {
+		c.RetryWait = 500 * time.Millisecond
+	}
+	if c.RetryMaxWait == 0 {
+		c.RetryMaxWait = 5 * time.Second
+	}
+
+	cli := &Client{client: resty.New(), logger: c.Logger}
+	cli.client.
+		SetBaseURL(c.BaseUrl).
+		SetTimeout(c.Timeout).
+		SetHeaders(c.Headers).
+		SetLogger(restyLogger{cli}).
+		SetRetryWaitTime(c.RetryWait).
+		SetRetryMaxWaitTime(c.RetryMaxWait).
+		AddRetryCondition(shouldRetry).
+		AddRetryHook(cli.onRetry).
+		OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+			if id := log.RequestId(r.Context()); id != "" && r.Header.Get(HeaderRequestId) == "" {
+				r.SetHeader(HeaderRequestId, id)
+			}
+			return nil
+		}).
+		OnAfterResponse(cli.onResponse)
+	if c.RetryCount > 0 {
+		cli.client.SetRetryCount(c.RetryCount)
+	}
+	if c.Transport != nil {
+		cli.client.SetTransport(c.Transport)
+	}
+	return cli
+}
+
+type retryKey struct{}
+
+// WithRetry 覆盖 ctx 发起的请求是否重试。retry 为 true 时 POST 等非幂等请求也重试，
+// 仅用于重复发送没有副作用的请求；为 false 时不重试，如以 GET 调用的创建接口
+func WithRetry(ctx context.Context, retry bool) context.Context {
+	return context.WithValue(ctx, retryKey{}, retry)
+}
+
+// shouldRetry 在 5xx 与网络错误时重试幂等请求，避免重复创建资源
+func shouldRetry(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil {
+		return false
+	}
+	if retry, ok := resp.Request.Context().Value(retryKey{}).(bool); ok {
+		if !retry {
+			return false
+		}
+	} else {
+		switch resp.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		default:
+			return false
+		}
+	}
+	return err != nil || resp.StatusCode() >= http.StatusInternalServerError
+}
+
+func (c *Client) log() *log.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return log.Named("httpclient")
+}
+
+// Resty 返回底层的 resty.Client，用于本包未覆盖的用法
+func (c *Client) Resty() *resty.Client {
+	return c.client
+}
+
+// R 创建绑定 ctx 的请求
+func (c *Client) R(ctx context.Context) *resty.Request {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.client.R().SetContext(ctx)
+}
+
+// Call 请求返回 apiutil.Response 的接口，将 Data 解码到 data。
+// 非 2xx 响应返回包装了 *apiutil.Error 的错误，响应不是 apiutil.Response 时返回错误，
+// 不返回信封的接口使用 Do
+func (c *Client) Call(ctx context.Context, method, path string, body any, data any) error {
+	req := c.R(ctx)
+	if body != nil {
+		req.SetBody(body)
+	}
+	resp, err := req.Execute(method, path)
+	if err != nil {
+		return errutil.WrapF("%s %s failed: %w", method, path, err)
+	}
+
+	r := apiutil.Response{Data: data}
+	err = json.Unmarshal(resp.Body(), &r)
+	enveloped := err == nil && isEnvelope(resp.Body())
+	if !resp.IsSuccess() {
+		return errutil.WrapF("%s %s failed: %w", method, path, responseError(resp, r, enveloped))
+	}
+	if err != nil {
+		return errutil.WrapF("%s %s: decode response failed: %w", method, path, err)
+	}
+	if !enveloped {
+		return errutil.WrapF("%s %s: response is not an apiutil.Response", method, path)
+	}
+	return nil
+}
+
+// isEnvelope 以是否包含 code 字段判断响应体是否为 apiutil.Response
+func isEnvelope(body []byte) bool {
+	var v struct {
+		Code *int `json:"code"`
+	}
+	return json.Unmarshal(body, &v) == nil && v.Code != nil
+}
+
+// Do 发起请求，body 按 JSON 编码，将响应体按 JSON 解码到 v，适用于第三方接口
+func (c *Client) Do(ctx context.Context, method, path string, body any, v any) error {
+	req := c.R(ctx)
+	if body != nil {
+		req.SetBody(body)
+	}
+	return c.do(req, method, path, v)
+}
+
+// Get 以查询参数发起 GET 请求，将响应体按 JSON 解码到 v，适用于第三方接口
+func (c *Client) Get(ctx context.Context, path string, query map[string]string, v any) error {
+	return c.do(c.R(ctx).SetQueryParams(query), http.MethodGet, path, v)
+}
+
+// PostForm 以表单发起 POST 请求，将响应体按 JSON 解码到 v
+func (c *Client) PostForm(ctx context.Context, path string, form map[string]string, v any) error {
+	return c.do(c.R(ctx).SetFormData(form), http.MethodPost, path, v)
+}
+
+// PostJSON 以 JSON 发起 POST 请求，将响应体按 JSON 解码到 v
+func (c *Client) PostJSON(ctx context.Context, path string, body any, v any) error {
+	return c.do(c.R(ctx).SetBody(body), http.MethodPost, path, v)
+}
+
+func (c *Client) do(req *resty.Request, method, path string, v any) error {
+	resp, err := req.Execute(method, path)
+	if err != nil {
+		return errutil.WrapF("%s %s failed: %w", method, path, err)
+	}
+	if !resp.IsSuccess() {
+		return errutil.WrapF("%s %s failed: %w", method, path, responseError(resp, apiutil.Response{}, false))
+	}
+	if v == nil {
+		return nil
+	}
+	err = json.Unmarshal(resp.Body(), v)
+	if err != nil {
+		return errutil.WrapF("%s %s: decode response failed: %w", method, path, err)
+	}
+	return nil
+}
+
+// responseError 将非 2xx 响应转换为 *apiutil.Error，没有响应信封时使用响应体作为消息
+func responseError(resp *resty.Response, r apiutil.Response, enveloped bool) *apiutil.Error {
+	if !enveloped || (r.Message == "" && r.Code == 0) {
+		body := resp.String()
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody] + "..."
+		}
+		if body == "" {
+			body = http.StatusText(resp.StatusCode())
+		}
+		return apiutil.NewError(resp.StatusCode(), resp.StatusCode(), body)
+	}
+	e := apiutil.NewError(resp.StatusCode(), r.Code, r.Message)
+	e.Fields = r.Fields
+	return e
+}
+
+func (c *Client) onResponse(_ *resty.Client, resp *resty.Response) error {
+	ctx := resp.Request.Context()
+	args := []any{
+		"method", resp.Request.Method,
+		"url", resp.Request.URL,
+		"code", resp.StatusCode(),
+		"latency", resp.Time(),
+	}
+	if resp.Request.Attempt > 1 {
+		args = append(args, "attempt", resp.Request.Attempt)
+	}
+	if resp.IsError() {
+		c.log().WarnC(ctx, "http request failed", args...)
+	} else {
+		c.log().DebugC(ctx, "http request", args...)
+	}
+	return nil
+}
+
+func (c *Client) onRetry(resp *resty.Response, err error) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	args := []any{
+		"method", resp.Request.Method,
+		"url", resp.Request.URL,
+		"attempt", resp.Request.Attempt,
+	}
+	if err != nil {
+		args = append(args, "err", err)
+	} else {
+		args = append(args, "code", resp.StatusCode())
+	}
+	c.log().WarnC(resp.Request.Context(), "retry http request", args...)
+}
+
+// restyLogger 将 resty 的内部日志输出到 pkg/log
+type restyLogger struct {
+	client *Client
+}
+
+func (l restyLogger) Errorf(format string, v ...any) {
+	l.client.log().ErrorF(format, v...)
+}
+
+func (l restyLogger) Warnf(format string, v ...any) {
+	l.client.log().WarnF(format, v...)
+}
+
+func (l restyLogger) Debugf(format string, v ...any) {
+	l.client.log().DebugF(format, v...)
+}
+
+// IsStatus 判断 err 是否为指定状态码的响应错误
+func IsStatus(err error, status int) bool {
+	var e *apiutil.Error
+	return errors.As(err, &e) && e.Status == status
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func newServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	gin.SetMode(gin.TestMode)
+	var flaky atomic.Int32
+	e := gin.New()
+	e.GET("/item", func(c *gin.Context) {
+		apiutil.OK(c, item{Name: c.GetHeader(HeaderRequestId)})
+	})
+	e.GET("/missing", func(c *gin.Context) {
+		apiutil.Fail(c, errutil.WrapNotFound("item"))
+	})
+	flakyHandler := func(c *gin.Context) {
+		if flaky.Add(1) < 3 {
+			c.String(http.StatusBadGateway, "bad gateway")
+			return
+		}
+		apiutil.OK(c, item{Name: "ok"})
+	}
+	e.GET("/flaky", flakyHandler)
+	e.POST("/flaky", flakyHandler)
+	e.GET("/raw", func(c *gin.Context) {
+		c.JSON(http.StatusOK, item{Name: c.Query("name")})
+	})
+	e.POST("/form", func(c *gin.Context) {
+		c.JSON(http.StatusOK, item{Name: c.PostForm("name")})
+	})
+	s := httptest.NewServer(e)
+	t.Cleanup(s.Close)
+	return s, &flaky
+}
+
+func newClient(url string) *Client {
+	return New(Config{BaseUrl: url, RetryWait: time.Millisecond, RetryMaxWait: 5 * time.Millisecond})
+}
+
+func TestCall(t *testing.T) {
+	s, _ := newServer(t)
+	c := newClient(s.URL)
+
+	ctx := log.WithRequestId(context.Background(), "rid-1")
+	var data item
+	require.NoError(t, c.Call(ctx, http.MethodGet, "/item", nil, &data))
+	assert.Equal(t, "rid-1", data.Name)
+
+	err := c.Call(ctx, http.MethodGet, "/missing", nil, &data)
+	var e *apiutil.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusNotFound, e.Status)
+	assert.Equal(t, apiutil.CodeNotFound, e.Code)
+	assert.True(t, IsStatus(err, http.StatusNotFound))
+
+	// 不返回信封的接口不会被当作成功的空数据
+	data = item{}
+	err = c.Call(ctx, http.MethodGet, "/raw?name=a", nil, &data)
+	assert.ErrorContains(t, err, "not an apiutil.Response")
+}
+
+func TestRetry(t *testing.T) {
+	s, flaky := newServer(t)
+
+	var data item
+	require.NoError(t, newClient(s.URL).Call(context.Background(), http.MethodGet, "/flaky", nil, &data))
+	assert.Equal(t, "ok", data.Name)
+	assert.EqualValues(t, 3, flaky.Load())
+
+	flaky.Store(0)
+	c := New(Config{BaseUrl: s.URL, RetryCount: -1})
+	err := c.Call(context.Background(), http.MethodGet, "/flaky", nil, &data)
+	assert.True(t, IsStatus(err, http.StatusBadGateway))
+	assert.Contains(t, err.Error(), "bad gateway")
+	assert.EqualValues(t, 1, flaky.Load())
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	s, flaky := newServer(t)
+	c := newClient(s.URL)
+
+	// POST 默认不重试，避免重复创建
+	var data item
+	err := c.Call(context.Background(), http.MethodPost, "/flaky", item{}, &data)
+	assert.True(t, IsStatus(err, http.StatusBadGateway))
+	assert.EqualValues(t, 1, flaky.Load())
+
+	flaky.Store(0)
+	require.NoError(t, c.Call(WithRetry(context.Background(), true), http.MethodPost, "/flaky", item{}, &data))
+	assert.Equal(t, "ok", data.Name)
+	assert.EqualValues(t, 3, flaky.Load())
+
+	flaky.Store(0)
+	err = c.Call(WithRetry(context.Background(), false), http.MethodGet, "/flaky", nil, &data)
+	assert.True(t, IsStatus(err, http.StatusBadGateway))
+	assert.EqualValues(t, 1, flaky.Load())
+}
+
+func TestRaw(t *testing.T) {
+	s, _ := newServer(t)
+	c := newClient(s.URL)
+
+	var data item
+	require.NoError(t, c.Get(context.Background(), "/raw", map[string]string{"name": "a"}, &data))
+	assert.Equal(t, "a", data.Name)
+	require.NoError(t, c.PostForm(context.Background(), "/form", map[string]string{"name": "b"}, &data))
+	assert.Equal(t, "b", data.Name)
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransport(t *testing.T) {
+	s, _ := newServer(t)
+	var calls atomic.Int32
+	c := New(Config{
+		BaseUrl: "http://example.invalid",
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			r.URL.Scheme = "http"
+			r.URL.Host = s.Listener.Addr().String()
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	})
+
+	var data item
+	require.NoError(t, c.Get(context.Background(), "/raw", map[string]string{"name": "t"}, &data))
+	assert.Equal(t, "t", data.Name)
+	assert.EqualValues(t, 1, calls.Load())
+}
@@ -0,0 +1,181 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Sink 描述一个日志输出目标
+type Sink struct {
+	// Writer 为输出目标，为空时按 Path 打开文件，Path 也为空时输出到 stdout
+	Writer io.Writer
+	Path   string
+	Rotate RotateConfig
+	// Format 为 FormatText 或 FormatJSON，默认 FormatText
+	Format string
+	// Level 为该输出的固定级别，为空时跟随 Logger 的级别
+	Level string
+	// NoColor 关闭终端彩色输出，仅在输出到终端且为文本格式时生效
+	NoColor bool
+	// Handler 为自定义的 slog.Handler，设置后忽略以上输出相关字段，
+	// 仍会经过 ContextHandler 注入上下文字段
+	Handler slog.Handler
+}
+
+func (s Sink) handler(lvl slog.Leveler) (slog.Handler, error) {
+	if s.Level != "" {
+		var l slog.Level
+		err := l.UnmarshalText([]byte(s.Level))
+		if err != nil {
+			return nil, errutil.Wrap(err)
+		}
+		lvl = l
+	}
+	if s.Handler != nil {
+		return &levelHandler{Handler: s.Handler, level: lvl}, nil
+	}
+
+	// 按 Path 打开的文件已由 New 设置为 Writer
+	w := s.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       lvl,
+		ReplaceAttr: replaceAttr,
+	}
+	switch s.Format {
+	case FormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	case FormatText, "":
+		if !s.NoColor && isTerminal(w) {
+			w = &colorWriter{w: w}
+		}
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, errutil.WrapF("unknown log format: %s", s.Format)
+	}
+}
+
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey {
+		source, ok := a.Value.Any().(*slog.Source)
+		if ok {
+			dir, file := filepath.Split(source.File)
+			source.File = fmt.Sprintf("%s/%s", filepath.Base(dir), file)
+		}
+		return a
+	}
+	return redactAttr(a)
+}
+
+// levelHandler 为自定义 handler 附加级别控制
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// fanoutHandler 将日志分发到多个 handler，各自按级别过滤
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, hh := range h {
+		if hh.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, hh := range h {
+		if hh.Enabled(ctx, r.Level) {
+			errs = append(errs, hh.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hs := make(fanoutHandler, len(h))
+	for i, hh := range h {
+		hs[i] = hh.WithAttrs(attrs)
+	}
+	return hs
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	hs := make(fanoutHandler, len(h))
+	for i, hh := range h {
+		hs[i] = hh.WithGroup(name)
+	}
+	return hs
+}
+
+var levelColors = []struct {
+	level []byte
+	color string
+}{
+	{[]byte(" level=DEBUG"), "\x1b[90m"},
+	{[]byte(" level=WARN"), "\x1b[33m"},
+	{[]byte(" level=ERROR"), "\x1b[31m"},
+}
+
+// colorWriter 按级别为整行日志着色，slog 的 handler 每次 Write 恰为一条日志
+type colorWriter struct {
+	w io.Writer
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	for _, lc := range levelColors {
+		if bytes.Contains(p, lc.level) {
+			line := make([]byte, 0, len(p)+len(lc.color)+4)
+			line = append(line, lc.color...)
+			line = append(line, bytes.TrimSuffix(p, []byte("\n"))...)
+			line = append(line, "\x1b[0m\n"...)
+			_, err := c.w.Write(line)
+			return len(p), err
+		}
+	}
+	return c.w.Write(p)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
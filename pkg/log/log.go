@@ -2,12 +2,12 @@ package log
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/vksir/vkiss-lib/pkg/util/errutil"
 	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime"
 	"time"
 )
@@ -20,6 +20,13 @@ func DefaultLogger() *Logger {
 	return logger
 }
 
+// SetDefaultLogger 替换默认 logger，返回原来的 logger
+func SetDefaultLogger(l *Logger) *Logger {
+	old := logger
+	logger = l
+	return old
+}
+
 func SetLevel(level string) error {
 	return logger.SetLevel(level)
 }
@@ -102,9 +109,27 @@ func Close(closer io.Closer) {
 type Logger struct {
 	logger *slog.Logger
 	level  *slog.LevelVar
+	// name 为具名 logger 的名称，见 Logger.Named
+	name string
+	// files 为 New 按 Sink.Path 打开的日志文件，由 Close 关闭
+	files []*RotateWriter
+}
+
+// Close 关闭 New 打开的日志文件，With 与 Named 派生的 logger 不持有文件
+func (l *Logger) Close() error {
+	var errs []error
+	for _, f := range l.files {
+		errs = append(errs, f.Close())
+	}
+	l.files = nil
+	return errors.Join(errs...)
 }
 
+// SetLevel 设置级别，具名 logger 只影响自身及其子 logger
 func (l *Logger) SetLevel(level string) error {
+	if l.name != "" {
+		return SetModuleLevel(l.name, level)
+	}
 	err := l.level.UnmarshalText([]byte(level))
 	if err != nil {
 		return errutil.Wrap(err)
@@ -113,7 +138,7 @@ func (l *Logger) SetLevel(level string) error {
 }
 
 func (l *Logger) With(args ...any) *Logger {
-	return &Logger{logger: l.logger.With(args...), level: l.level}
+	return &Logger{logger: l.logger.With(args...), level: l.level, name: l.name}
 }
 
 func (l *Logger) Log(level slog.Level, msg string, args ...any) {
@@ -196,14 +221,22 @@ func (l *Logger) ErrorFC(ctx context.Context, format string, a ...any) {
 	l.logF(ctx, slog.LevelError, format, a...)
 }
 
+func (l *Logger) enabled(ctx context.Context, level slog.Level) bool {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return level >= l.Level() && l.logger.Enabled(ctx, level)
+}
+
 func (l *Logger) log(ctx context.Context, level slog.Level, msg string, args ...any) {
-	if !l.logger.Enabled(ctx, level) {
+	if !l.enabled(ctx, level) {
 		return
 	}
 	var pcs [1]uintptr
 	// skip [runtime.Callers, this function, this function's caller]
 	runtime.Callers(3, pcs[:])
 	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	l.addName(&r)
 	r.Add(args...)
 	if ctx == nil {
 		ctx = context.Background()
@@ -211,54 +244,98 @@ func (l *Logger) log(ctx context.Context, level slog.Level, msg string, args ...
 	_ = l.logger.Handler().Handle(ctx, r)
 }
 
+func (l *Logger) addName(r *slog.Record) {
+	if l.name != "" {
+		r.AddAttrs(slog.String(loggerKey, l.name))
+	}
+}
+
 func (l *Logger) logF(ctx context.Context, level slog.Level, format string, a ...any) {
-	if !l.logger.Enabled(ctx, level) {
+	if !l.enabled(ctx, level) {
 		return
 	}
 	var pcs [1]uintptr
 	// skip [runtime.Callers, this function, this function's caller]
 	runtime.Callers(3, pcs[:])
 	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, a...), pcs[0])
+	l.addName(&r)
 	if ctx == nil {
 		ctx = context.Background()
 	}
 	_ = l.logger.Handler().Handle(ctx, r)
 }
 
+type Config struct {
+	Path   string
+	Level  string
+	Rotate RotateConfig
+	// Format 为 stdout 与文件的输出格式
+	Format string
+	// ConsoleLevel 与 FileLevel 为各自的固定级别，为空时跟随 Level
+	ConsoleLevel string
+	FileLevel    string
+	// Sinks 不为空时替代由以上字段生成的 stdout 与文件输出
+	Sinks []Sink
+	// Modules 为具名 logger 的级别，见 Logger.Named
+	Modules map[string]string
+	// RingSize 大于 0 时在内存中保留最近的日志，见 DefaultRing
+	RingSize int
+}
+
+func (c Config) sinks() []Sink {
+	if len(c.Sinks) > 0 {
+		return c.Sinks
+	}
+	sinks := []Sink{{Writer: os.Stdout, Format: c.Format, Level: c.ConsoleLevel}}
+	if c.Path != "" {
+		sinks = append(sinks, Sink{Path: c.Path, Rotate: c.Rotate, Format: c.Format, Level: c.FileLevel})
+	}
+	return sinks
+}
+
 func NewLogger(path string) *Logger {
-	var w io.Writer = os.Stdout
-	if path != "" {
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	return New(Config{Path: path})
+}
+
+func New(c Config) *Logger {
+	lvl := &slog.LevelVar{}
+	var hs fanoutHandler
+	var files []*RotateWriter
+	for _, s := range c.sinks() {
+		if s.Handler == nil && s.Writer == nil && s.Path != "" {
+			file, err := NewRotateWriter(s.Path, s.Rotate)
+			errutil.Check(err)
+			files = append(files, file)
+			s.Writer = file
+		}
+		h, err := s.handler(levelAll)
 		errutil.Check(err)
-		w = io.MultiWriter(w, file)
+		hs = append(hs, h)
 	}
+	var h slog.Handler = hs
+	if len(hs) == 1 {
+		h = hs[0]
+	}
+	lg := slog.New(&ContextHandler{h})
+	return &Logger{logger: lg, level: lvl, files: files}
+}
 
+// NewWithHandler 使用自定义 handler 创建 Logger，级别由 Logger.SetLevel 控制
+func NewWithHandler(h slog.Handler) *Logger {
 	lvl := &slog.LevelVar{}
-
-	h := &ContextHandler{
-		slog.NewTextHandler(w, &slog.HandlerOptions{
-			AddSource: true,
-			Level:     lvl,
-			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-				if a.Key == slog.SourceKey {
-					source := a.Value.Any().(*slog.Source)
-					//source.File = source.Function[strings.LastIndex(source.Function, "/")+1:]
-					dir, file := filepath.Split(source.File)
-					source.File = fmt.Sprintf("%s/%s", filepath.Base(dir), file)
-				}
-				return a
-			},
-		}),
-	}
-	lg := slog.New(h)
+	lg := slog.New(&ContextHandler{h})
 	return &Logger{logger: lg, level: lvl}
 }
 
+// ContextHandler 将请求 ID 以及 AppendCtx 存入 context 的字段追加到每条日志
 type ContextHandler struct {
 	slog.Handler
 }
 
 func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIdKey, id))
+	}
 	args, ok := ctx.Value(slogFields).([]any)
 	if ok {
 		r.Add(args...)
@@ -266,6 +343,14 @@ func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
 	return h.Handler.Handle(ctx, r)
 }
 
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{h.Handler.WithGroup(name)}
+}
+
 func AppendCtx(ctx context.Context, args ...any) context.Context {
 	if ctx == nil {
 		ctx = context.Background()
@@ -278,8 +363,26 @@ func AppendCtx(ctx context.Context, args ...any) context.Context {
 }
 
 func Init(path string, level string) {
-	logger = NewLogger(path)
-	err := logger.SetLevel(level)
+	InitConfig(Config{Path: path, Level: level})
+}
+
+func InitConfig(c Config) {
+	gRing = nil
+	if c.RingSize > 0 {
+		gRing = NewRing(c.RingSize)
+		c.Sinks = append(c.sinks(), Sink{Handler: gRing.Handler()})
+	}
+	old := logger
+	logger = New(c)
+	// 关闭上一次初始化打开的日志文件，避免泄露文件描述符与 SIGHUP 注册
+	if err := old.Close(); err != nil {
+		logger.Error("close old log file failed", "err", err)
+	}
+	err := logger.SetLevel(c.Level)
 	errutil.Check(err)
-	logger.Warn("init log", "level", level, "path", path)
+	for name, level := range c.Modules {
+		err = SetModuleLevel(name, level)
+		errutil.Check(err)
+	}
+	logger.Warn("init log", "level", c.Level, "path", c.Path)
 }
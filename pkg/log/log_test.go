@@ -1,8 +1,16 @@
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLogger(t *testing.T) {
@@ -17,3 +25,71 @@ func TestLogger(t *testing.T) {
 	ctx = AppendCtx(ctx, "trace", "id2")
 	l.InfoFC(ctx, "msg4: %s=%s", "k4", "v4")
 }
+
+func TestSinks(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	textBuf := &bytes.Buffer{}
+	l := New(Config{Sinks: []Sink{
+		{Writer: jsonBuf, Format: FormatJSON, Level: "debug"},
+		{Writer: textBuf, Level: "warn"},
+	}})
+
+	ctx := AppendCtx(context.Background(), "trace", "id1")
+	l.With("module", "m1").InfoC(ctx, "msg1", "k1", "v1")
+	l.WarnC(ctx, "msg2")
+
+	lines := strings.Split(strings.TrimSpace(jsonBuf.String()), "\n")
+	assert.Len(t, lines, 2)
+	var record map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "msg1", record["msg"])
+	assert.Equal(t, "m1", record["module"])
+	assert.Equal(t, "id1", record["trace"])
+
+	assert.NotContains(t, textBuf.String(), "msg1")
+	assert.Contains(t, textBuf.String(), "msg=msg2")
+	assert.Contains(t, textBuf.String(), "trace=id1")
+}
+
+func TestNewWithHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewWithHandler(slog.NewJSONHandler(buf, nil))
+	assert.Nil(t, l.SetLevel("warn"))
+
+	ctx := AppendCtx(context.Background(), "trace", "id1")
+	l.InfoC(ctx, "msg1")
+	l.WarnC(ctx, "msg2")
+	assert.NotContains(t, buf.String(), "msg1")
+	assert.Contains(t, buf.String(), `"trace":"id1"`)
+}
+
+func TestRequestId(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(Config{Sinks: []Sink{{Writer: buf}}})
+
+	ctx := EnsureRequestId(context.Background())
+	id := RequestId(ctx)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, ctx, EnsureRequestId(ctx))
+
+	l.InfoC(AppendCtx(ctx, "tag", "starting"), "msg1")
+	assert.Contains(t, buf.String(), "msg=msg1 request_id="+id+" tag=starting")
+}
+
+func TestInitConfigClosesOldFile(t *testing.T) {
+	old := DefaultLogger()
+	defer SetDefaultLogger(old)
+
+	dir := t.TempDir()
+	InitConfig(Config{Path: filepath.Join(dir, "a.log"), Level: "info"})
+	first := DefaultLogger().files[0]
+	InitConfig(Config{Path: filepath.Join(dir, "b.log"), Level: "info"})
+	defer Close(DefaultLogger())
+
+	_, err := first.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	gWritersLock.Lock()
+	_, ok := gWriters[first]
+	gWritersLock.Unlock()
+	assert.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package logapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+// parseQuery 解析查询参数：level、since、until (RFC3339)、q、attr (k=v，可重复)、limit
+func parseQuery(c *gin.Context) (log.Query, error) {
+	var q log.Query
+	if s := c.Query("level"); s != "" {
+		var l slog.Level
+		err := l.UnmarshalText([]byte(s))
+		if err != nil {
+			return q, err
+		}
+		q.Level = &l
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if s := c.Query(name); s != "" {
+			v, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*t = v
+		}
+	}
+	q.Contains = c.Query("q")
+	for _, attr := range c.QueryArray("attr") {
+		k, v, ok := strings.Cut(attr, "=")
+		if !ok {
+			return q, fmt.Errorf("invalid attr: %s", attr)
+		}
+		if q.Attrs == nil {
+			q.Attrs = make(map[string]string)
+		}
+		q.Attrs[k] = v
+	}
+	if s := c.Query("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid limit: %w", err)
+		}
+		q.Limit = v
+	}
+	return q, nil
+}
+
+func ring(c *gin.Context) (*log.Ring, log.Query, bool) {
+	r := log.DefaultRing()
+	if r == nil {
+		apiutil.Fail(c, apiutil.ErrNotFound.Wrap(errors.New("log ring is disabled, set log.ring_size")))
+		return nil, log.Query{}, false
+	}
+	q, err := parseQuery(c)
+	if err != nil {
+		apiutil.Fail(c, apiutil.ErrBadRequest.Wrap(err))
+		return nil, q, false
+	}
+	return r, q, true
+}
+
+func listRecords(c *gin.Context) {
+	r, q, ok := ring(c)
+	if !ok {
+		return
+	}
+	apiutil.OK(c, r.Query(q))
+}
+
+// streamRecords 以 SSE 推送符合条件的新日志
+func streamRecords(c *gin.Context) {
+	r, q, ok := ring(c)
+	if !ok {
+		return
+	}
+	ch, cancel := r.Subscribe()
+	defer cancel()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case e := <-ch:
+			if q.Match(e) {
+				c.SSEvent("record", e)
+			}
+			return true
+		}
+	})
+}
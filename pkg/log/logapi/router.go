@@ -0,0 +1,53 @@
+package logapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+// RootModule 为默认 logger 在接口中的名称
+const RootModule = "root"
+
+func LoadRouter(g *gin.RouterGroup) {
+	g.GET("/log/levels", listLevels)
+	g.PUT("/log/levels/:name", setLevel)
+	g.GET("/log/records", listRecords)
+	g.GET("/log/records/stream", streamRecords)
+}
+
+type SetLevelRequest struct {
+	// Level 为空时恢复继承父级
+	Level string `json:"level"`
+}
+
+func listLevels(c *gin.Context) {
+	modules := append([]log.ModuleLevel{{
+		Name:      RootModule,
+		Level:     log.DefaultLogger().Level().String(),
+		Effective: log.DefaultLogger().Level().String(),
+	}}, log.Modules()...)
+	apiutil.OK(c, modules)
+}
+
+func setLevel(c *gin.Context) {
+	var req SetLevelRequest
+	err := apiutil.Bind(c, &req)
+	if err != nil {
+		apiutil.Fail(c, err)
+		return
+	}
+
+	name := c.Param("name")
+	if name == RootModule {
+		err = log.SetLevel(req.Level)
+	} else {
+		err = log.SetModuleLevel(name, req.Level)
+	}
+	if err != nil {
+		apiutil.Fail(c, apiutil.ErrBadRequest.Wrap(err))
+		return
+	}
+	log.Warn("set log level", "module", name, "level", req.Level)
+	apiutil.OK(c, nil)
+}
@@ -0,0 +1,126 @@
+// Package logtest 提供记录日志的 log.Logger，用于在单元测试中断言日志输出
+package logtest
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+// Recorder 记录所有经过其 Logger 的日志，级别默认为 debug
+type Recorder struct {
+	logger *log.Logger
+
+	mu      sync.RWMutex
+	entries []log.Entry
+}
+
+func New() *Recorder {
+	r := &Recorder{}
+	r.logger = log.New(log.Config{Sinks: []log.Sink{{Handler: log.NewEntryHandler(r.add)}}})
+	_ = r.logger.SetLevel("debug")
+	return r
+}
+
+// ReplaceDefault 将默认 logger 替换为 Recorder 的 Logger，测试结束时恢复
+func ReplaceDefault(t testing.TB) *Recorder {
+	r := New()
+	old := log.SetDefaultLogger(r.logger)
+	t.Cleanup(func() {
+		log.SetDefaultLogger(old)
+	})
+	return r
+}
+
+func (r *Recorder) Logger() *log.Logger {
+	return r.logger
+}
+
+func (r *Recorder) add(e log.Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+}
+
+func (r *Recorder) Entries() []log.Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]log.Entry(nil), r.entries...)
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// Find 返回级别与消息完全一致，且包含 attrs 中所有字段的日志。
+// attrs 为 key, value 交替的列表，value 以 fmt.Sprint 的结果比较
+func (r *Recorder) Find(level slog.Level, msg string, attrs ...any) []log.Entry {
+	q := log.Query{Attrs: attrMap(attrs)}
+	var res []log.Entry
+	for _, e := range r.Entries() {
+		if e.Level == level.String() && e.Message == msg && q.Match(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Query 返回符合 log.Query 条件的日志
+func (r *Recorder) Query(q log.Query) []log.Entry {
+	var res []log.Entry
+	for _, e := range r.Entries() {
+		if q.Match(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (r *Recorder) Has(level slog.Level, msg string, attrs ...any) bool {
+	return len(r.Find(level, msg, attrs...)) > 0
+}
+
+// AssertLogged 断言存在符合条件的日志
+func (r *Recorder) AssertLogged(t testing.TB, level slog.Level, msg string, attrs ...any) bool {
+	t.Helper()
+	if r.Has(level, msg, attrs...) {
+		return true
+	}
+	t.Errorf("no log found: level=%s msg=%q attrs=%v\nlogs:\n%s", level, msg, attrMap(attrs), r)
+	return false
+}
+
+// AssertNotLogged 断言不存在符合条件的日志
+func (r *Recorder) AssertNotLogged(t testing.TB, level slog.Level, msg string, attrs ...any) bool {
+	t.Helper()
+	if !r.Has(level, msg, attrs...) {
+		return true
+	}
+	t.Errorf("unexpected log found: level=%s msg=%q attrs=%v", level, msg, attrMap(attrs))
+	return false
+}
+
+func (r *Recorder) String() string {
+	var b strings.Builder
+	for _, e := range r.Entries() {
+		_, _ = fmt.Fprintf(&b, "  %s %q %v\n", e.Level, e.Message, e.Attrs)
+	}
+	return b.String()
+}
+
+func attrMap(attrs []any) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		m[fmt.Sprint(attrs[i])] = fmt.Sprint(attrs[i+1])
+	}
+	return m
+}
@@ -0,0 +1,28 @@
+package logtest
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+func TestRecorder(t *testing.T) {
+	r := ReplaceDefault(t)
+
+	log.Info("msg1", "k1", "v1", "err", errors.New("e1"))
+	log.Named("logtest").Debug("msg2")
+
+	assert.True(t, r.Has(slog.LevelInfo, "msg1"))
+	assert.True(t, r.Has(slog.LevelInfo, "msg1", "k1", "v1", "err", errors.New("e1")))
+	assert.False(t, r.Has(slog.LevelInfo, "msg1", "k1", "v2"))
+	assert.False(t, r.Has(slog.LevelWarn, "msg1"))
+	r.AssertLogged(t, slog.LevelDebug, "msg2", "logger", "logtest")
+	r.AssertNotLogged(t, slog.LevelDebug, "msg3")
+	assert.Len(t, r.Query(log.Query{Contains: "msg"}), 2)
+
+	r.Reset()
+	assert.Empty(t, r.Entries())
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// levelAll 交给 handler 的级别，实际的级别过滤在 Logger 中按模块完成
+const levelAll = slog.Level(math.MinInt)
+
+const loggerKey = "logger"
+
+var (
+	// gModules 记录所有具名 logger，值为覆盖的级别，nil 表示继承父级
+	gModules     = make(map[string]*slog.Level)
+	gModulesLock sync.RWMutex
+)
+
+type ModuleLevel struct {
+	Name string `json:"name"`
+	// Level 为覆盖的级别，为空表示继承父级
+	Level string `json:"level"`
+	// Effective 为生效的级别
+	Effective string `json:"effective"`
+}
+
+// Named 返回具名子 logger，名称以 . 与父级连接，如 service.master。
+// 未单独设置级别时继承父级，最终继承 Logger 本身的级别
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	gModulesLock.Lock()
+	if _, ok := gModules[name]; !ok {
+		gModules[name] = nil
+	}
+	gModulesLock.Unlock()
+	return &Logger{logger: l.logger, level: l.level, name: name}
+}
+
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Level 返回生效的级别
+func (l *Logger) Level() slog.Level {
+	gModulesLock.RLock()
+	defer gModulesLock.RUnlock()
+	return l.effective(l.name)
+}
+
+func (l *Logger) effective(name string) slog.Level {
+	for name != "" {
+		if lvl := gModules[name]; lvl != nil {
+			return *lvl
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.level.Level()
+}
+
+func Named(name string) *Logger {
+	return logger.Named(name)
+}
+
+// SetModuleLevel 设置具名 logger 的级别，level 为空时恢复继承父级
+func SetModuleLevel(name, level string) error {
+	var lvl *slog.Level
+	if level != "" {
+		lvl = new(slog.Level)
+		err := lvl.UnmarshalText([]byte(level))
+		if err != nil {
+			return errutil.Wrap(err)
+		}
+	}
+	gModulesLock.Lock()
+	defer gModulesLock.Unlock()
+	gModules[name] = lvl
+	return nil
+}
+
+// Modules 列出所有具名 logger 及其级别，生效级别以默认 logger 为根计算
+func Modules() []ModuleLevel {
+	gModulesLock.RLock()
+	defer gModulesLock.RUnlock()
+	modules := make([]ModuleLevel, 0, len(gModules))
+	for name, lvl := range gModules {
+		m := ModuleLevel{Name: name, Effective: logger.effective(name).String()}
+		if lvl != nil {
+			m.Level = lvl.String()
+		}
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules
+}
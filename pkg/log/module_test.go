@@ -0,0 +1,46 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamed(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(Config{Sinks: []Sink{{Writer: buf}}})
+	assert.Nil(t, l.SetLevel("info"))
+
+	parent := l.Named("named_test")
+	child := parent.Named("child").With("k", "v")
+	assert.Equal(t, "named_test.child", child.Name())
+
+	child.Debug("msg1")
+	assert.NotContains(t, buf.String(), "msg1")
+
+	assert.Nil(t, SetModuleLevel("named_test", "debug"))
+	child.Debug("msg2")
+	l.Debug("msg3")
+	assert.Contains(t, buf.String(), "msg=msg2 k=v logger=named_test.child")
+	assert.NotContains(t, buf.String(), "msg3")
+
+	assert.Nil(t, child.SetLevel("error"))
+	child.Info("msg4")
+	parent.Info("msg5")
+	assert.NotContains(t, buf.String(), "msg4")
+	assert.Contains(t, buf.String(), "msg5")
+
+	var found bool
+	for _, m := range Modules() {
+		if m.Name == "named_test.child" {
+			found = true
+			assert.Equal(t, "ERROR", m.Level)
+		}
+	}
+	assert.True(t, found)
+
+	assert.Nil(t, SetModuleLevel("named_test.child", ""))
+	child.Debug("msg6")
+	assert.Contains(t, buf.String(), "msg6")
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const redacted = "******"
+
+// minSecretLen 过短的值替换后会误伤正常日志，不作为敏感信息处理
+const minSecretLen = 4
+
+var (
+	gSecrets     []string
+	gSecretsLock sync.RWMutex
+)
+
+// AddSecret 登记敏感值，之后日志中出现的该值均会被替换为 ******
+func AddSecret(secret string) {
+	if len(secret) < minSecretLen {
+		return
+	}
+	gSecretsLock.Lock()
+	defer gSecretsLock.Unlock()
+	for _, s := range gSecrets {
+		if s == secret {
+			return
+		}
+	}
+	gSecrets = append(gSecrets, secret)
+}
+
+// Redact 将 s 中已登记的敏感值替换为 ******
+func Redact(s string) string {
+	gSecretsLock.RLock()
+	defer gSecretsLock.RUnlock()
+	for _, secret := range gSecrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+func hasSecrets() bool {
+	gSecretsLock.RLock()
+	defer gSecretsLock.RUnlock()
+	return len(gSecrets) > 0
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if !hasSecrets() {
+		return a
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		s := fmt.Sprint(a.Value.Any())
+		if r := Redact(s); r != s {
+			a.Value = slog.StringValue(r)
+		}
+	default:
+	}
+	return a
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type requestIdKey struct{}
+
+// RequestIdKey 为日志中请求 ID 的字段名
+const RequestIdKey = "request_id"
+
+func NewRequestId() string {
+	return uuid.NewString()
+}
+
+// WithRequestId 将请求 ID 存入 context，之后所有 *C 日志均会带上该字段
+func WithRequestId(ctx context.Context, id string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// EnsureRequestId 在 context 中没有请求 ID 时生成一个
+func EnsureRequestId(ctx context.Context) context.Context {
+	if RequestId(ctx) != "" {
+		return ctx
+	}
+	return WithRequestId(ctx, NewRequestId())
+}
+
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry 为 Ring 中保存的结构化日志
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"msg"`
+	Source  string            `json:"source,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// Query 为 Ring 的查询条件，零值字段不参与过滤
+type Query struct {
+	// Level 为最低级别
+	Level *slog.Level
+	Since time.Time
+	Until time.Time
+	// Contains 为消息中包含的子串
+	Contains string
+	// Attrs 为需要完全匹配的字段，如 subprocess=master
+	Attrs map[string]string
+	// Limit 为返回的最大条数，保留最新的记录
+	Limit int
+}
+
+func (q *Query) Match(e Entry) bool {
+	if q.Level != nil {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(e.Level)); err == nil && l < *q.Level {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Contains != "" && !strings.Contains(e.Message, q.Contains) {
+		return false
+	}
+	for k, v := range q.Attrs {
+		if e.Attrs[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Ring 在内存中保存最近的 size 条日志，并支持订阅新日志
+type Ring struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int
+	full    bool
+	subs    map[chan Entry]struct{}
+}
+
+func NewRing(size int) *Ring {
+	return &Ring{entries: make([]Entry, size), subs: make(map[chan Entry]struct{})}
+}
+
+// Handler 返回写入 Ring 的 slog.Handler，可作为 Sink.Handler 使用
+func (r *Ring) Handler() slog.Handler {
+	return NewEntryHandler(r.add)
+}
+
+func (r *Ring) add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	for ch := range r.subs {
+		// 订阅方处理不过来时丢弃，不阻塞日志输出
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Query 按时间顺序返回符合条件的日志
+func (r *Ring) Query(q Query) []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var all []Entry
+	if r.full {
+		all = append(all, r.entries[r.next:]...)
+	}
+	all = append(all, r.entries[:r.next]...)
+
+	var res []Entry
+	for _, e := range all {
+		if q.Match(e) {
+			res = append(res, e)
+		}
+	}
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[len(res)-q.Limit:]
+	}
+	return res
+}
+
+// Subscribe 订阅新日志，调用返回的函数取消订阅
+func (r *Ring) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, 64)
+	r.mu.Lock()
+	r.subs[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.subs, ch)
+		r.mu.Unlock()
+	}
+}
+
+// NewEntryHandler 返回将日志转换为 Entry 后交给 f 的 slog.Handler
+func NewEntryHandler(f func(e Entry)) slog.Handler {
+	return &entryHandler{f: f}
+}
+
+type entryHandler struct {
+	f      func(e Entry)
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h *entryHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *entryHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: Redact(r.Message),
+		Attrs:   make(map[string]string),
+	}
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		dir, file := filepath.Split(f.File)
+		e.Source = fmt.Sprintf("%s/%s:%d", filepath.Base(dir), file, f.Line)
+	}
+	prefix := strings.Join(h.groups, ".")
+	for _, a := range h.attrs {
+		addEntryAttr(e.Attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addEntryAttr(e.Attrs, prefix, a)
+		return true
+	})
+	h.f(e)
+	return nil
+}
+
+func (h *entryHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefix := strings.Join(h.groups, ".")
+	var grouped []slog.Attr
+	for _, a := range attrs {
+		if prefix != "" {
+			a.Key = prefix + "." + a.Key
+		}
+		grouped = append(grouped, a)
+	}
+	return &entryHandler{f: h.f, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], grouped...), groups: h.groups}
+}
+
+func (h *entryHandler) WithGroup(name string) slog.Handler {
+	return &entryHandler{f: h.f, attrs: h.attrs, groups: append(h.groups[:len(h.groups):len(h.groups)], name)}
+}
+
+func addEntryAttr(m map[string]string, prefix string, a slog.Attr) {
+	a = redactAttr(a)
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			addEntryAttr(m, key, ga)
+		}
+		return
+	}
+	m[key] = a.Value.String()
+}
+
+var gRing *Ring
+
+// DefaultRing 返回 InitConfig 按 Config.RingSize 创建的 Ring，未开启时返回 nil
+func DefaultRing() *Ring {
+	return gRing
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+	l := New(Config{Sinks: []Sink{{Handler: ring.Handler()}}})
+	assert.Nil(t, l.SetLevel("debug"))
+
+	ch, cancel := ring.Subscribe()
+	defer cancel()
+
+	l.Debug("msg1")
+	l.With("subprocess", "master").Info("msg2", "k", "v")
+	l.Warn("msg3")
+	l.InfoC(WithRequestId(context.Background(), "id1"), "msg4")
+
+	all := ring.Query(Query{})
+	assert.Len(t, all, 3)
+	assert.Equal(t, "msg2", all[0].Message)
+	assert.Equal(t, "id1", all[2].Attrs[RequestIdKey])
+
+	warn := slog.LevelWarn
+	assert.Len(t, ring.Query(Query{Level: &warn}), 1)
+	assert.Len(t, ring.Query(Query{Contains: "msg"}), 3)
+	assert.Len(t, ring.Query(Query{Limit: 1}), 1)
+	res := ring.Query(Query{Attrs: map[string]string{"subprocess": "master"}})
+	assert.Len(t, res, 1)
+	assert.Equal(t, "v", res[0].Attrs["k"])
+	assert.Len(t, ring.Query(Query{Since: time.Now().Add(time.Minute)}), 0)
+
+	select {
+	case e := <-ch:
+		assert.Equal(t, "msg1", e.Message)
+	default:
+		t.Fatal("no record received")
+	}
+}
@@ -0,0 +1,323 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type RotateConfig struct {
+	// MaxSize 为单个文件的最大字节数，0 表示不按大小切分
+	MaxSize int64
+	// Interval 为按时间切分的周期，如 24h，0 表示不按时间切分
+	Interval time.Duration
+	// MaxBackups 为保留的历史文件数，0 表示不限制
+	MaxBackups int
+	// MaxAge 为历史文件的最长保留时间，0 表示不限制
+	MaxAge time.Duration
+	// Compress 为 true 时使用 gzip 压缩历史文件
+	Compress bool
+}
+
+// RotateWriter 为按大小与时间切分的日志文件，可并发写入。
+// 历史文件命名为 name-<time>.ext，开启压缩时追加 .gz
+type RotateWriter struct {
+	path string
+	conf RotateConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	millMu     sync.Mutex
+}
+
+func NewRotateWriter(path string, conf RotateConfig) (*RotateWriter, error) {
+	w := &RotateWriter{path: path, conf: conf}
+	err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	registerWriter(w)
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, errutil.Wrap(os.ErrClosed)
+	}
+	if w.shouldRotate(int64(len(p))) {
+		err := w.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即切分当前文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// Reopen 关闭并重新打开日志文件，配合外部 logrotate 使用
+func (w *RotateWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		_ = w.file.Close()
+	}
+	return w.open()
+}
+
+func (w *RotateWriter) Close() error {
+	unregisterWriter(w)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	if w.conf.MaxSize > 0 && w.size > 0 && w.size+n > w.conf.MaxSize {
+		return true
+	}
+	return w.conf.Interval > 0 && !time.Now().Before(w.nextRotate)
+}
+
+func (w *RotateWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(w.path), 0o755)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return errutil.Wrap(err)
+	}
+	w.file = file
+	w.size = stat.Size()
+	if w.conf.Interval > 0 {
+		w.nextRotate = time.Now().Truncate(w.conf.Interval).Add(w.conf.Interval)
+	}
+	return nil
+}
+
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	if w.size > 0 {
+		err := os.Rename(w.path, w.backupName(time.Now()))
+		if err != nil && !os.IsNotExist(err) {
+			return errutil.Wrap(err)
+		}
+	}
+	err := w.open()
+	if err != nil {
+		return err
+	}
+	go w.mill()
+	return nil
+}
+
+// backupName 返回以 t 命名的历史文件名，同一毫秒内已存在 (含压缩后的) 历史文件时追加序号
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, name := filepath.Split(w.path)
+	ext := filepath.Ext(name)
+	base := filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+t.Format(backupTimeFormat))
+	for seq := 0; ; seq++ {
+		path := base + ext
+		if seq > 0 {
+			path = base + "-" + strconv.Itoa(seq) + ext
+		}
+		if !fileExists(path) && !fileExists(path+".gz") {
+			return path
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+type backupFile struct {
+	path string
+	t    time.Time
+	seq  int
+}
+
+// backups 返回所有历史文件，按时间从新到旧排序
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	dir, name := filepath.Split(w.path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errutil.Wrap(err)
+	}
+	var files []backupFile
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		ts := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(n, ".gz"), ext), prefix)
+		if len(ts) < len(backupTimeFormat) {
+			continue
+		}
+		// 文件名中的时间为本地时间，之后可能带有序号，见 backupName
+		t, err := time.ParseInLocation(backupTimeFormat, ts[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		seq := 0
+		if rest := ts[len(backupTimeFormat):]; rest != "" {
+			n, ok := strings.CutPrefix(rest, "-")
+			seq, err = strconv.Atoi(n)
+			if !ok || err != nil || seq <= 0 {
+				continue
+			}
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, n), t: t, seq: seq})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].t.Equal(files[j].t) {
+			return files[i].t.After(files[j].t)
+		}
+		return files[i].seq > files[j].seq
+	})
+	return files, nil
+}
+
+// mill 清理过期的历史文件并压缩
+func (w *RotateWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	files, err := w.backups()
+	if err != nil {
+		return
+	}
+	var keep []backupFile
+	for i, f := range files {
+		if (w.conf.MaxBackups > 0 && i >= w.conf.MaxBackups) ||
+			(w.conf.MaxAge > 0 && time.Since(f.t) > w.conf.MaxAge) {
+			_ = os.Remove(f.path)
+			continue
+		}
+		keep = append(keep, f)
+	}
+	if !w.conf.Compress {
+		return
+	}
+	for _, f := range keep {
+		if strings.HasSuffix(f.path, ".gz") {
+			continue
+		}
+		if err := gzipFile(f.path); err == nil {
+			_ = os.Remove(f.path)
+		}
+	}
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return errutil.Wrap(err)
+	}
+	gw := gzip.NewWriter(dst)
+	_, err = io.Copy(gw, src)
+	if err == nil {
+		err = gw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return errutil.Wrap(err)
+	}
+	return nil
+}
+
+var (
+	gWriters     = make(map[*RotateWriter]struct{})
+	gWritersLock sync.Mutex
+	gSighupOnce  sync.Once
+)
+
+func registerWriter(w *RotateWriter) {
+	gWritersLock.Lock()
+	gWriters[w] = struct{}{}
+	gWritersLock.Unlock()
+
+	// 收到 SIGHUP 时重新打开所有日志文件
+	gSighupOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				reopenWriters()
+			}
+		}()
+	})
+}
+
+func unregisterWriter(w *RotateWriter) {
+	gWritersLock.Lock()
+	delete(gWriters, w)
+	gWritersLock.Unlock()
+}
+
+func reopenWriters() {
+	gWritersLock.Lock()
+	defer gWritersLock.Unlock()
+	for w := range gWriters {
+		err := w.Reopen()
+		if err != nil {
+			// 日志文件不可用，只能输出到 stderr
+			_, _ = fmt.Fprintln(os.Stderr, "reopen log file failed:", w.path, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(path, RotateConfig{MaxSize: 10, MaxBackups: 2, Compress: true})
+	assert.Nil(t, err)
+	defer Close(w)
+
+	for i := 0; i < 4; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		assert.Nil(t, err)
+		// 保证历史文件名中的时间戳不同
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool {
+		entries, _ := os.ReadDir(dir)
+		var gz int
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".log.gz") {
+				gz++
+			}
+		}
+		return len(entries) == 3 && gz == 2
+	}, 3*time.Second, 20*time.Millisecond)
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(content))
+}
+
+func TestRotateWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(path, RotateConfig{})
+	assert.Nil(t, err)
+	defer Close(w)
+
+	_, err = w.Write([]byte("line1\n"))
+	assert.Nil(t, err)
+
+	// 模拟外部 logrotate：移走文件后发送 SIGHUP
+	assert.Nil(t, os.Rename(path, path+".1"))
+	p, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	assert.Nil(t, p.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 3*time.Second, 20*time.Millisecond)
+
+	_, err = w.Write([]byte("line2\n"))
+	assert.Nil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "line2\n", string(content))
+}
+
+func TestRotateWriterBackupsLocal(t *testing.T) {
+	old := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	defer func() { time.Local = old }()
+
+	dir := t.TempDir()
+	w := &RotateWriter{path: filepath.Join(dir, "app.log")}
+	now := time.Now().Truncate(time.Millisecond)
+	assert.Nil(t, os.WriteFile(w.backupName(now), nil, 0o644))
+
+	files, err := w.backups()
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.True(t, now.Equal(files[0].t))
+}
+
+func TestRotateWriterBackupNameCollision(t *testing.T) {
+	dir := t.TempDir()
+	w := &RotateWriter{path: filepath.Join(dir, "app.log")}
+	now := time.Now().Truncate(time.Millisecond)
+
+	first := w.backupName(now)
+	assert.Nil(t, os.WriteFile(first+".gz", nil, 0o644))
+	second := w.backupName(now)
+	assert.NotEqual(t, first, second)
+	assert.Nil(t, os.WriteFile(second, nil, 0o644))
+	third := w.backupName(now)
+	assert.NotEqual(t, second, third)
+	assert.Nil(t, os.WriteFile(third, nil, 0o644))
+
+	// 同一时间的历史文件按序号从新到旧排序
+	files, err := w.backups()
+	assert.Nil(t, err)
+	assert.Len(t, files, 3)
+	assert.Equal(t, []string{third, second, first + ".gz"}, []string{files[0].path, files[1].path, files[2].path})
+}
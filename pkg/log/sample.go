@@ -0,0 +1,125 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SampleConfig 在每个 Interval 内，同一 key 的日志先放行 First 条，之后每 Thereafter 条放行一条，
+// 其余丢弃，并在 Interval 结束时输出被丢弃的数量
+type SampleConfig struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+	// Key 返回日志的分组 key，默认按级别与消息分组
+	Key func(r slog.Record) string
+}
+
+var DefaultSampleConfig = SampleConfig{Interval: time.Second, First: 100, Thereafter: 100}
+
+type sampleCounter struct {
+	count      int
+	suppressed int
+	level      slog.Level
+	msg        string
+	handler    slog.Handler
+}
+
+type sampler struct {
+	conf SampleConfig
+
+	mu       sync.Mutex
+	counters map[string]*sampleCounter
+	timer    *time.Timer
+}
+
+// SampleHandler 为限流采样的 slog.Handler，WithAttrs 派生的 handler 共享计数
+type SampleHandler struct {
+	slog.Handler
+	s *sampler
+}
+
+func NewSampleHandler(h slog.Handler, c SampleConfig) *SampleHandler {
+	if c.Interval <= 0 {
+		c.Interval = DefaultSampleConfig.Interval
+	}
+	if c.Key == nil {
+		c.Key = func(r slog.Record) string {
+			return r.Level.String() + ":" + r.Message
+		}
+	}
+	return &SampleHandler{Handler: h, s: &sampler{conf: c, counters: make(map[string]*sampleCounter)}}
+}
+
+func (h *SampleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.s.allow(h.Handler, r) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *SampleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SampleHandler{Handler: h.Handler.WithAttrs(attrs), s: h.s}
+}
+
+func (h *SampleHandler) WithGroup(name string) slog.Handler {
+	return &SampleHandler{Handler: h.Handler.WithGroup(name), s: h.s}
+}
+
+func (s *sampler) allow(h slog.Handler, r slog.Record) bool {
+	key := s.conf.Key(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.conf.Interval, s.flush)
+	}
+	c, ok := s.counters[key]
+	if !ok {
+		c = &sampleCounter{}
+		s.counters[key] = c
+	}
+	c.count++
+	if c.count <= s.conf.First {
+		return true
+	}
+	if s.conf.Thereafter > 0 && (c.count-s.conf.First)%s.conf.Thereafter == 0 {
+		return true
+	}
+	c.suppressed++
+	c.level, c.msg, c.handler = r.Level, r.Message, h
+	return false
+}
+
+// flush 结束当前周期，输出被丢弃的日志数量
+func (s *sampler) flush() {
+	s.mu.Lock()
+	counters := s.counters
+	s.counters = make(map[string]*sampleCounter)
+	s.timer = nil
+	s.mu.Unlock()
+
+	for _, c := range counters {
+		if c.suppressed == 0 {
+			continue
+		}
+		r := slog.NewRecord(time.Now(), c.level, "suppressed messages", 0)
+		r.AddAttrs(
+			slog.String("suppressed_msg", c.msg),
+			slog.Int("suppressed", c.suppressed),
+			slog.Duration("interval", s.conf.Interval),
+		)
+		_ = c.handler.Handle(context.Background(), r)
+	}
+}
+
+// Sampled 返回限流采样的 logger，用于输出量大的来源，如子进程输出
+func (l *Logger) Sampled(c SampleConfig) *Logger {
+	return &Logger{
+		logger: slog.New(NewSampleHandler(l.logger.Handler(), c)),
+		level:  l.level,
+		name:   l.name,
+	}
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampled(t *testing.T) {
+	buf := &syncBuffer{}
+	l := New(Config{Sinks: []Sink{{Writer: buf}}}).
+		Sampled(SampleConfig{Interval: 100 * time.Millisecond, First: 2, Thereafter: 3})
+
+	for i := 0; i < 10; i++ {
+		l.Info("flood")
+	}
+	l.Info("other")
+	// 放行第 1、2、5、8 条
+	assert.Equal(t, 4, strings.Count(buf.String(), " msg=flood\n"))
+	assert.Contains(t, buf.String(), "msg=other")
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "msg=\"suppressed messages\" suppressed_msg=flood suppressed=6")
+	}, time.Second, 10*time.Millisecond)
+
+	// 新的周期重新计数
+	l.Info("flood")
+	assert.Equal(t, 5, strings.Count(buf.String(), " msg=flood\n"))
+}
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+const (
+	HeaderApiKey = "X-API-Key"
+	// HeaderTimestamp、HeaderNonce 与 HeaderSignature 为 HmacAuth 的请求头
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+	// AuthUserKey 为认证通过后存入 gin.Context 的用户名，TokenAuth 不设置
+	AuthUserKey = "auth_user"
+)
+
+// TokenAuth 校验 Authorization: Bearer <token> 或 X-API-Key 请求头，tokens 为空时拒绝所有请求
+func TokenAuth(tokens ...string) gin.HandlerFunc {
+	hashes := make([][sha256.Size]byte, 0, len(tokens))
+	for _, t := range tokens {
+		if t != "" {
+			hashes = append(hashes, sha256.Sum256([]byte(t)))
+		}
+	}
+	return func(c *gin.Context) {
+		token := c.GetHeader(HeaderApiKey)
+		if token == "" {
+			token = bearerToken(c.GetHeader("Authorization"))
+		}
+		if token != "" && matchToken(hashes, token) {
+			c.Next()
+			return
+		}
+		c.Header("WWW-Authenticate", `Bearer`)
+		abortUnauthorized(c)
+	}
+}
+
+// BasicAuth 校验 HTTP Basic 认证，accounts 为用户名到密码的映射
+func BasicAuth(accounts map[string]string, realm string) gin.HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	hashes := make(map[string][sha256.Size]byte, len(accounts))
+	for user, password := range accounts {
+		hashes[user] = sha256.Sum256([]byte(password))
+	}
+	return func(c *gin.Context) {
+		user, password, ok := c.Request.BasicAuth()
+		if ok {
+			want, exist := hashes[user]
+			got := sha256.Sum256([]byte(password))
+			if exist && subtle.ConstantTimeCompare(want[:], got[:]) == 1 {
+				c.Set(AuthUserKey, user)
+				c.Next()
+				return
+			}
+		}
+		c.Header("WWW-Authenticate", `Basic realm="`+strings.ReplaceAll(realm, `"`, `\"`)+`"`)
+		abortUnauthorized(c)
+	}
+}
+
+// DefaultHmacSkew 为 HmacAuth 允许的时间戳偏差
+const DefaultHmacSkew = 5 * time.Minute
+
+// maxNonceLen 为 X-Nonce 的最大长度，限制 nonce 缓存占用的内存
+const maxNonceLen = 64
+
+// HmacSign 返回请求的签名：hex(HMAC-SHA256(secret, method + "\n" + path + "\n" + timestamp + "\n" + nonce))。
+// path 为相对服务根路径的请求路径，不含查询参数，经过带路径前缀的反向代理时客户端应去掉 base URL 的路径；
+// timestamp 为 Unix 秒，nonce 为每个请求不同的随机串
+func HmacSign(secret, method, path string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HmacAuth 校验 HmacSign 生成的 X-Signature、X-Timestamp 与 X-Nonce 请求头，
+// 时间戳与服务端相差超过 skew 时拒绝，skew 为 0 时使用 DefaultHmacSkew。
+// 时间窗口内重复的 nonce 视为重放而拒绝，nonce 只保存在内存中，重启后窗口内的请求可被重放一次
+func HmacAuth(secret string, skew time.Duration) gin.HandlerFunc {
+	if skew == 0 {
+		skew = DefaultHmacSkew
+	}
+	nonces := &nonceCache{seen: make(map[string]time.Time)}
+	return func(c *gin.Context) {
+		ts, err := strconv.ParseInt(c.GetHeader(HeaderTimestamp), 10, 64)
+		nonce := c.GetHeader(HeaderNonce)
+		if err == nil && secret != "" && nonce != "" && len(nonce) <= maxNonceLen &&
+			time.Since(time.Unix(ts, 0)).Abs() <= skew {
+			want := HmacSign(secret, c.Request.Method, c.Request.URL.Path, ts, nonce)
+			// 签名正确后再记录 nonce，避免未认证的请求占用缓存
+			if hmac.Equal([]byte(want), []byte(c.GetHeader(HeaderSignature))) &&
+				nonces.add(nonce, time.Unix(ts, 0).Add(skew)) {
+				c.Next()
+				return
+			}
+		}
+		abortUnauthorized(c)
+	}
+}
+
+// nonceCache 记录时间窗口内出现过的 nonce
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextSweep time.Time
+}
+
+// add 记录 nonce 直到 expire，已存在时返回 false
+func (n *nonceCache) add(nonce string, expire time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	if now.After(n.nextSweep) {
+		for k, e := range n.seen {
+			if now.After(e) {
+				delete(n.seen, k)
+			}
+		}
+		n.nextSweep = now.Add(time.Minute)
+	}
+	if e, ok := n.seen[nonce]; ok && !now.After(e) {
+		return false
+	}
+	n.seen[nonce] = expire
+	return true
+}
+
+func bearerToken(auth string) string {
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// matchToken 比较哈希值以避免泄露 token 长度
+func matchToken(hashes [][sha256.Size]byte, token string) bool {
+	got := sha256.Sum256([]byte(token))
+	matched := 0
+	for _, want := range hashes {
+		matched |= subtle.ConstantTimeCompare(want[:], got[:])
+	}
+	return matched == 1
+}
+
+func abortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, apiutil.Response{
+		Message: http.StatusText(http.StatusUnauthorized),
+		Code:    http.StatusUnauthorized,
+	})
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(TokenAuth("t1", "t2"))
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for _, tc := range []struct {
+		header, value string
+		code          int
+	}{
+		{"Authorization", "Bearer t1", http.StatusOK},
+		{"Authorization", "bearer t2", http.StatusOK},
+		{HeaderApiKey, "t2", http.StatusOK},
+		{"Authorization", "Bearer t3", http.StatusUnauthorized},
+		{"Authorization", "Basic t1", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, "%s: %s", tc.header, tc.value)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(BasicAuth(map[string]string{"admin": "pass"}, ""))
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.GetString(AuthUserKey)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("admin", "pass")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("admin", "wrong")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic realm=")
+}
+
+func TestHmacAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(HmacAuth("secret", time.Minute))
+	e.GET("/my_ip", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	now := time.Now().Unix()
+	for _, tc := range []struct {
+		name      string
+		secret    string
+		path      string
+		timestamp int64
+		nonce     string
+		code      int
+	}{
+		{"ok", "secret", "/my_ip", now, "n1", http.StatusOK},
+		{"replay", "secret", "/my_ip", now, "n1", http.StatusUnauthorized},
+		{"wrong secret", "other", "/my_ip", now, "n2", http.StatusUnauthorized},
+		{"wrong path", "secret", "/other", now, "n3", http.StatusUnauthorized},
+		{"expired", "secret", "/my_ip", now - 120, "n4", http.StatusUnauthorized},
+		{"no nonce", "secret", "/my_ip", now, "", http.StatusUnauthorized},
+		// 签名错误的请求不占用 nonce
+		{"after wrong secret", "secret", "/my_ip", now, "n2", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/my_ip?x=1", nil)
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(tc.timestamp, 10))
+		req.Header.Set(HeaderNonce, tc.nonce)
+		req.Header.Set(HeaderSignature, HmacSign(tc.secret, http.MethodGet, tc.path, tc.timestamp, tc.nonce))
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tc.code, w.Code, tc.name)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/my_ip", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
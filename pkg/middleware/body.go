@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+const redacted = "******"
+
+var (
+	defaultMaxBodySize      = 4096
+	defaultBodyContentTypes = []string{
+		"application/json",
+		"application/x-www-form-urlencoded",
+		"application/xml",
+		"text/plain",
+		"text/xml",
+	}
+	defaultRedactKeys    = []string{"password", "passwd", "secret", "secret_id", "secret_key", "token", "access_token"}
+	defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+)
+
+// limitedBuffer 只保留前 max 字节，记录是否被截断
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.max - b.Len(); left < len(p) {
+		b.truncated = true
+		if left > 0 {
+			b.Buffer.Write(p[:left])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// teeRequestBody 在 handler 之前读取请求体的前 max 字节，再拼回 Request.Body
+func teeRequestBody(c *gin.Context, max int) *limitedBuffer {
+	buf := &limitedBuffer{max: max}
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return buf
+	}
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(max)+1))
+	if err != nil {
+		log.ErrorC(c.Request.Context(), "read request body failed", "err", err)
+	}
+	_, _ = buf.Write(head)
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	return buf
+}
+
+type responseWriter struct {
+	gin.ResponseWriter
+	buffer       *limitedBuffer
+	contentTypes []string
+	checked      bool
+	capture      bool
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.tee(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.tee([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) tee(b []byte) {
+	if !w.checked {
+		w.checked = true
+		w.capture = allowContentType(w.Header().Get("Content-Type"), w.contentTypes)
+	}
+	if w.capture {
+		_, _ = w.buffer.Write(b)
+	}
+}
+
+func allowContentType(contentType string, allowed []string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+	return false
+}
+
+type redactor struct {
+	keys    map[string]struct{}
+	jsonRe  *regexp.Regexp
+	headers map[string]struct{}
+}
+
+func newRedactor(keys, headers []string) *redactor {
+	r := &redactor{keys: make(map[string]struct{}), headers: make(map[string]struct{})}
+	quoted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		r.keys[strings.ToLower(k)] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(k))
+	}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	if len(quoted) > 0 {
+		// 截断后无法解析的 JSON 退化为按正则替换
+		r.jsonRe = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return r
+}
+
+// body 按 Content-Type 脱敏，只替换需要脱敏的值，保留原文的格式与字段顺序
+func (r *redactor) body(contentType string, b []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json":
+		if out, ok := r.json(b); ok {
+			return log.Redact(string(out))
+		}
+		if r.jsonRe != nil {
+			return log.Redact(r.jsonRe.ReplaceAllString(string(b), `$1"`+redacted+`"`))
+		}
+	case "application/x-www-form-urlencoded":
+		return log.Redact(r.form(string(b)))
+	}
+	return log.Redact(string(b))
+}
+
+// json 在原文上将需要脱敏的字段值替换为 redacted，截断或无法解析时返回 false。
+// 与 json.Unmarshal 一致，尾部有多余内容时视为无法解析
+func (r *redactor) json(b []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	// 每层对象或数组的状态，'k' 为对象中等待 key，'v' 为对象中等待值，'a' 为数组
+	var stack []byte
+	var out []byte
+	var last int64
+	for started := false; ; started = true {
+		if started && len(stack) == 0 {
+			if _, err := dec.Token(); err != io.EOF {
+				return nil, false
+			}
+			return append(out, b[last:]...), true
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		top := len(stack) - 1
+		if key, ok := tok.(string); ok && top >= 0 && stack[top] == 'k' {
+			if _, ok := r.keys[strings.ToLower(key)]; !ok {
+				stack[top] = 'v'
+				continue
+			}
+			// 值从 key 之后的冒号与空白之后开始
+			start := dec.InputOffset()
+			for start < int64(len(b)) && strings.ContainsRune(" \t\r\n:", rune(b[start])) {
+				start++
+			}
+			if !skipJSONValue(dec) {
+				return nil, false
+			}
+			out = append(append(out, b[last:start]...), `"`+redacted+`"`...)
+			last = dec.InputOffset()
+			continue
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:top]
+			continue
+		}
+		if top >= 0 && stack[top] == 'v' {
+			stack[top] = 'k'
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, 'k')
+		case json.Delim('['):
+			stack = append(stack, 'a')
+		}
+	}
+}
+
+// skipJSONValue 跳过一个完整的值，返回是否成功
+func skipJSONValue(dec *json.Decoder) bool {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return true
+		}
+	}
+}
+
+// form 将需要脱敏的字段值替换为 redacted，不重新编码其他字段
+func (r *redactor) form(s string) string {
+	pairs := strings.Split(s, "&")
+	for i, pair := range pairs {
+		raw, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(raw)
+		if err != nil {
+			key = raw
+		}
+		if _, ok := r.keys[strings.ToLower(key)]; ok {
+			pairs[i] = raw + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (r *redactor) header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if _, ok := r.headers[http.CanonicalHeaderKey(k)]; ok {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
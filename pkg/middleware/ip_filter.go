@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+	"github.com/vksir/vkiss-lib/pkg/util/netutil"
+)
+
+// AllowIps 只允许客户端 IP 属于 prefixes 的请求，其余返回 403。
+// 客户端 IP 取自 ClientIP，经过代理时需先配置 Engine 的可信代理
+func AllowIps(prefixes []netip.Prefix) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err == nil && netutil.ContainsAddr(prefixes, addr) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, apiutil.Response{
+			Message: http.StatusText(http.StatusForbidden),
+			Code:    http.StatusForbidden,
+		})
+	}
+}
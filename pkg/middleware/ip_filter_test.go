@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/util/netutil"
+)
+
+func TestAllowIps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ps, err := netutil.ParsePrefixes([]string{"10.0.0.0/8", "2001:db8::1"})
+	require.NoError(t, err)
+	e := gin.New()
+	e.Use(AllowIps(ps))
+	e.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for remote, code := range map[string]int{
+		"10.1.2.3:80":          http.StatusOK,
+		"[::ffff:10.1.2.3]:80": http.StatusOK,
+		"[2001:db8::1]:80":     http.StatusOK,
+		"[2001:db8::2]:80":     http.StatusForbidden,
+		"192.168.1.1:80":       http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, remote)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/vksir/vkiss-lib/pkg/log"
-	"io"
 	"log/slog"
 	"net/http"
 	"time"
@@ -13,8 +12,17 @@ import (
 
 type LogFormatterParams struct {
 	gin.LogFormatterParams
-	Ctx          context.Context
+	Ctx context.Context
+	// ResponseBody 为截取的原始响应体，未脱敏
 	ResponseBody *bytes.Buffer
+	// RedactedRequestBody 与 RedactedResponseBody 为截断并脱敏后的内容，Content-Type 不在白名单内时为空。
+	// 脱敏只替换字段值，其余内容与原文一致
+	RedactedRequestBody   string
+	RequestBodyTruncated  bool
+	RedactedResponseBody  string
+	ResponseBodyTruncated bool
+	// Header 为脱敏后的请求头，仅在 LoggerConfig.LogHeaders 时设置
+	Header http.Header
 }
 
 type LogFormatter func(params LogFormatterParams, logger *log.Logger)
@@ -30,6 +38,22 @@ type LoggerConfig struct {
 	// Skip is a Skipper that indicates which logs should not be written.
 	// Optional.
 	Skip gin.Skipper
+
+	// MaxRequestBody 与 MaxResponseBody 为记录的最大字节数，0 使用默认值 4096，小于 0 不记录
+	MaxRequestBody  int
+	MaxResponseBody int
+
+	// BodyContentTypes 为允许记录 body 的 Content-Type，默认为 JSON、表单、XML 与纯文本
+	BodyContentTypes []string
+
+	// RedactKeys 为 JSON 与表单中需要脱敏的字段名，不区分大小写，默认包含 password、secret_key 等
+	RedactKeys []string
+
+	// LogHeaders 为 true 时记录请求头
+	LogHeaders bool
+
+	// RedactHeaders 为需要脱敏的请求头，默认包含 Authorization、Cookie 等
+	RedactHeaders []string
 }
 
 var defaultLogFormatter = func(params LogFormatterParams, logger *log.Logger) {
@@ -44,36 +68,30 @@ var defaultLogFormatter = func(params LogFormatterParams, logger *log.Logger) {
 		params.Latency = params.Latency.Truncate(time.Second)
 	}
 
-	requestBody, err := io.ReadAll(params.Request.Body)
-	if err != nil {
-		logger.Error("read request body failed", "err", err)
-	}
-
-	logger.LogC(params.Ctx, level,
-		params.Path,
+	args := []any{
 		slog.Int("code", params.StatusCode),
 		slog.String("method", params.Method),
 		slog.Duration("latency", params.Latency),
 		slog.String("client", params.ClientIP),
-		slog.String("request", string(requestBody)),
-		slog.String("response", params.ResponseBody.String()),
-	)
+		slog.String("request", params.RedactedRequestBody),
+		slog.String("response", params.RedactedResponseBody),
+	}
+	if params.RequestBodyTruncated {
+		args = append(args, slog.Bool("request_truncated", true))
+	}
+	if params.ResponseBodyTruncated {
+		args = append(args, slog.Bool("response_truncated", true))
+	}
+	if params.Header != nil {
+		args = append(args, slog.Any("header", params.Header))
+	}
+	logger.LogC(params.Ctx, level, params.Path, args...)
 }
 
 var defaultSkipper = func(c *gin.Context) bool {
 	return c.Request.Method == http.MethodGet
 }
 
-type responseWriter struct {
-	gin.ResponseWriter
-	buffer *bytes.Buffer
-}
-
-func (w *responseWriter) Write(b []byte) (int, error) {
-	w.buffer.Write(b)
-	return w.ResponseWriter.Write(b)
-}
-
 func Logger(conf LoggerConfig, logger *log.Logger) gin.HandlerFunc {
 	formatter := conf.Formatter
 	if formatter == nil {
@@ -83,6 +101,21 @@ func Logger(conf LoggerConfig, logger *log.Logger) gin.HandlerFunc {
 	if skipper == nil {
 		skipper = defaultSkipper
 	}
+	maxRequestBody := bodySize(conf.MaxRequestBody)
+	maxResponseBody := bodySize(conf.MaxResponseBody)
+	contentTypes := conf.BodyContentTypes
+	if contentTypes == nil {
+		contentTypes = defaultBodyContentTypes
+	}
+	redactKeys := conf.RedactKeys
+	if redactKeys == nil {
+		redactKeys = defaultRedactKeys
+	}
+	redactHeaders := conf.RedactHeaders
+	if redactHeaders == nil {
+		redactHeaders = defaultRedactHeaders
+	}
+	r := newRedactor(redactKeys, redactHeaders)
 
 	notlogged := conf.SkipPaths
 	var skip map[string]struct{}
@@ -99,11 +132,22 @@ func Logger(conf LoggerConfig, logger *log.Logger) gin.HandlerFunc {
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
 
-		buffer := bytes.NewBuffer(nil)
-		c.Writer = &responseWriter{
+		// 在 handler 读取之前截取请求体
+		requestContentType := c.GetHeader("Content-Type")
+		requestBody := &limitedBuffer{}
+		if maxRequestBody > 0 && allowContentType(requestContentType, contentTypes) {
+			requestBody = teeRequestBody(c, maxRequestBody)
+		}
+
+		w := &responseWriter{
 			ResponseWriter: c.Writer,
-			buffer:         buffer,
+			buffer:         &limitedBuffer{max: maxResponseBody},
+			contentTypes:   contentTypes,
+		}
+		if maxResponseBody <= 0 {
+			w.checked = true
 		}
+		c.Writer = w
 
 		// Process request
 		c.Next()
@@ -118,8 +162,19 @@ func Logger(conf LoggerConfig, logger *log.Logger) gin.HandlerFunc {
 				Request: c.Request,
 				Keys:    c.Keys,
 			},
-			Ctx:          c,
-			ResponseBody: buffer,
+			Ctx:                   c.Request.Context(),
+			ResponseBody:          &w.buffer.Buffer,
+			RequestBodyTruncated:  requestBody.truncated,
+			ResponseBodyTruncated: w.buffer.truncated,
+		}
+		if requestBody.Len() > 0 {
+			param.RedactedRequestBody = r.body(requestContentType, requestBody.Bytes())
+		}
+		if w.buffer.Len() > 0 {
+			param.RedactedResponseBody = r.body(w.Header().Get("Content-Type"), w.buffer.Bytes())
+		}
+		if conf.LogHeaders {
+			param.Header = r.header(c.Request.Header)
 		}
 
 		// Stop timer
@@ -142,3 +197,10 @@ func Logger(conf LoggerConfig, logger *log.Logger) gin.HandlerFunc {
 		formatter(param, logger)
 	}
 }
+
+func bodySize(n int) int {
+	if n == 0 {
+		return defaultMaxBodySize
+	}
+	return n
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/log/logtest"
+)
+
+func newLoggerEngine(conf LoggerConfig, r *logtest.Recorder) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(Logger(conf, r.Logger()))
+	e.POST("/echo", func(c *gin.Context) {
+		b, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, c.GetHeader("Content-Type"), b)
+	})
+	e.POST("/ignore", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return e
+}
+
+func lastEntry(t *testing.T, r *logtest.Recorder) log.Entry {
+	entries := r.Entries()
+	require.NotEmpty(t, entries)
+	return entries[len(entries)-1]
+}
+
+func TestLoggerBody(t *testing.T) {
+	r := logtest.New()
+	e := newLoggerEngine(LoggerConfig{}, r)
+
+	body := `{"user":"a","password":"p@ss","nested":{"secret_key":"k"}}`
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	// handler 仍能读到完整请求体
+	assert.Equal(t, body, w.Body.String())
+	entry := lastEntry(t, r)
+	for _, k := range []string{"request", "response"} {
+		assert.Contains(t, entry.Attrs[k], `"user":"a"`)
+		assert.Contains(t, entry.Attrs[k], `"password":"******"`)
+		assert.Contains(t, entry.Attrs[k], `"secret_key":"******"`)
+		assert.NotContains(t, entry.Attrs[k], "p@ss")
+	}
+
+	// handler 未读取请求体时也能记录
+	r.Reset()
+	req = httptest.NewRequest(http.MethodPost, "/ignore", strings.NewReader("token=abc&name=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	entry = lastEntry(t, r)
+	assert.Equal(t, "token=******&name=b", entry.Attrs["request"])
+	assert.Equal(t, "ok", entry.Attrs["response"])
+}
+
+func TestLoggerBodyNumber(t *testing.T) {
+	r := logtest.New()
+	e := newLoggerEngine(LoggerConfig{}, r)
+
+	body := `{"id":9007199254740993,"count":1000000,"ratio":0.5,"password":"p"}`
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 数字按原文记录
+	entry := lastEntry(t, r)
+	assert.Equal(t, `{"id":9007199254740993,"count":1000000,"ratio":0.5,"password":"******"}`, entry.Attrs["request"])
+}
+
+func TestLoggerBodyLimit(t *testing.T) {
+	r := logtest.New()
+	e := newLoggerEngine(LoggerConfig{MaxRequestBody: 16, MaxResponseBody: -1}, r)
+
+	body := `{"password":"0123456789abcdef"}`
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, body, w.Body.String())
+	entry := lastEntry(t, r)
+	assert.Equal(t, `{"password":"******"`, entry.Attrs["request"])
+	assert.Equal(t, "true", entry.Attrs["request_truncated"])
+	assert.Equal(t, "", entry.Attrs["response"])
+}
+
+func TestLoggerContentType(t *testing.T) {
+	r := logtest.New()
+	e := newLoggerEngine(LoggerConfig{}, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("\x00\x01binary"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, "\x00\x01binary", w.Body.String())
+	entry := lastEntry(t, r)
+	assert.Equal(t, "", entry.Attrs["request"])
+	assert.Equal(t, "", entry.Attrs["response"])
+}
+
+func TestLoggerHeaders(t *testing.T) {
+	r := logtest.New()
+	e := newLoggerEngine(LoggerConfig{LogHeaders: true}, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/ignore", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("X-Custom", "v")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := lastEntry(t, r)
+	assert.Contains(t, entry.Attrs["header"], "Authorization:[******]")
+	assert.Contains(t, entry.Attrs["header"], "X-Custom:[v]")
+	assert.NotContains(t, entry.Attrs["header"], "abc")
+}
+
+func TestLoggerBodyFormat(t *testing.T) {
+	r := logtest.New()
+	var params LogFormatterParams
+	e := newLoggerEngine(LoggerConfig{Formatter: func(p LogFormatterParams, logger *log.Logger) {
+		params = p
+	}}, r)
+
+	body := "{\n  \"b\": [1, {\"token\": {\"x\": [2]}}],\n  \"a\" : \"v\",\n  \"Secret\" :\t12\n}"
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 只替换脱敏的值，保留原文的空白与字段顺序
+	want := "{\n  \"b\": [1, {\"token\": \"******\"}],\n  \"a\" : \"v\",\n  \"Secret\" :\t\"******\"\n}"
+	assert.Equal(t, want, params.RedactedRequestBody)
+	assert.Equal(t, want, params.RedactedResponseBody)
+	// ResponseBody 为未脱敏的原文
+	assert.Equal(t, body, params.ResponseBody.String())
+}
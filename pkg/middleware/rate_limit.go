@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+type RateLimitConfig struct {
+	// Rate 为每秒补充的令牌数
+	Rate float64
+	// Burst 为桶容量，默认为 max(1, Rate)
+	Burst int
+	// Key 返回限流的维度，默认为客户端 IP
+	Key func(c *gin.Context) string
+}
+
+// RateLimit 按 Key 使用令牌桶限流，超出时返回 429 并设置 Retry-After
+func RateLimit(conf RateLimitConfig) gin.HandlerFunc {
+	l := NewLimiter(conf.Rate, conf.Burst)
+	key := conf.Key
+	if key == nil {
+		key = func(c *gin.Context) string { return c.ClientIP() }
+	}
+	return func(c *gin.Context) {
+		ok, wait := l.Allow(key(c))
+		if ok {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, apiutil.Response{
+			Message: http.StatusText(http.StatusTooManyRequests),
+			Code:    http.StatusTooManyRequests,
+		})
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 为按 key 区分的令牌桶，长时间未使用的桶会被定期清理
+type Limiter struct {
+	rate    float64
+	burst   float64
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow 消耗一个令牌，不足时返回需要等待的时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep 删除已经补满的桶，这些桶与新建的桶没有区别
+func (l *Limiter) sweep(now time.Time) {
+	if l.rate <= 0 || now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, k)
+		}
+	}
+}
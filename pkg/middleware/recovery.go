@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+// Recovery 捕获 handler 中的 panic，记录堆栈并返回 500。
+// 客户端已断开连接时只记录日志，不再写响应
+func Recovery(logger *log.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = log.DefaultLogger()
+	}
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			ctx := c.Request.Context()
+			if brokenPipe(v) {
+				logger.WarnC(ctx, "connection broken", "path", c.Request.URL.Path, "err", v)
+				_ = c.Error(fmt.Errorf("%v", v))
+				c.Abort()
+				return
+			}
+			logger.ErrorC(ctx, "panic recovered",
+				"path", c.Request.URL.Path,
+				"method", c.Request.Method,
+				"err", v,
+				"stack", string(debug.Stack()))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, apiutil.Response{
+				Message: http.StatusText(http.StatusInternalServerError),
+				Code:    http.StatusInternalServerError,
+			})
+		}()
+		c.Next()
+	}
+}
+
+func brokenPipe(v any) bool {
+	err, ok := v.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/log/logtest"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := logtest.New()
+	e := gin.New()
+	e.Use(Recovery(r.Logger()))
+	e.GET("/", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var resp apiutil.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+
+	entries := r.Find(slog.LevelError, "panic recovered", "err", "boom")
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Attrs["stack"], "recovery_test.go")
+}
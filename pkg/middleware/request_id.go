@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+const HeaderRequestId = "X-Request-ID"
+
+// maxRequestIdLen 超长或含非法字符的请求 ID 会被替换，避免污染日志
+const maxRequestIdLen = 128
+
+// RequestId 沿用请求头中的 X-Request-ID，没有时生成一个，并写回响应头。
+// 请求 ID 存入 Request.Context，需在 Logger 之前注册
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestId)
+		if !validRequestId(id) {
+			id = log.NewRequestId()
+		}
+		c.Request = c.Request.WithContext(log.WithRequestId(c.Request.Context(), id))
+		c.Header(HeaderRequestId, id)
+		c.Next()
+	}
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLen {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
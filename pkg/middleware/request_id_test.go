@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
+func TestRequestId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(RequestId())
+	e.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, log.RequestId(c.Request.Context()))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestId, "abc-123")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Body.String())
+	assert.Equal(t, "abc-123", w.Header().Get(HeaderRequestId))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestId, "bad id\n")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Len(t, w.Body.String(), 36)
+	assert.Equal(t, w.Body.String(), w.Header().Get(HeaderRequestId))
+}
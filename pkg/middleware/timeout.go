@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/util/apiutil"
+)
+
+// Timeout 为请求设置超时，到期时取消 Request.Context。
+// handler 需自行响应 context 的取消；返回时若超时且未写入响应，则返回 504
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, apiutil.Response{
+				Message: http.StatusText(http.StatusGatewayTimeout),
+				Code:    http.StatusGatewayTimeout,
+			})
+		}
+	}
+}
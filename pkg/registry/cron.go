@@ -2,50 +2,153 @@ package registry
 
 import (
 	"context"
-	"github.com/vksir/vkiss-lib/pkg/log"
+	"sync"
 	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/log"
 )
 
-var gCronJobCancel = make(map[string]context.CancelFunc)
+// gCronJobs 记录每个名称当前注册的任务，任务退出时只清理自己的注册
+var gCronJobs = make(map[string]*cronJobEntry)
+var gCronJobLock sync.Mutex
+
+type cronJobEntry struct {
+	cancel context.CancelFunc
+}
 
 type CronJob func(ctx context.Context) error
 
+// CronJobConfig 为 RegisterCronJobWithConfig 的配置
+type CronJobConfig struct {
+	// Interval 返回执行成功后到下次执行的间隔，每次执行后调用，使配置重载后生效
+	Interval func() time.Duration
+	// Backoff 返回执行失败后的首次重试间隔，连续失败时翻倍，不超过 Interval。为 nil 时失败后同样使用 Interval
+	Backoff func() time.Duration
+	// Immediate 为 true 时注册后立即执行一次，否则等待 Interval
+	Immediate bool
+	// Grace 为停止调度后正在执行的任务还可以运行的时间，超时后取消任务的 ctx，0 表示等待任务结束
+	Grace time.Duration
+}
+
+// next 返回连续失败 failures 次后到下次执行的间隔
+func (c CronJobConfig) next(failures int) time.Duration {
+	interval := c.Interval()
+	if failures == 0 || c.Backoff == nil {
+		return interval
+	}
+	d := c.Backoff()
+	for i := 1; i < failures && d < interval; i++ {
+		d *= 2
+	}
+	return min(d, interval)
+}
+
 func RegisterCronJob(name string, interval time.Duration, job CronJob) {
-	ctx, cancel := context.WithCancel(context.Background())
-	gCronJobCancel[name] = cancel
+	RegisterCronJobWithConfig(context.Background(), name, CronJobConfig{
+		Interval: func() time.Duration { return interval },
+	}, job)
+}
 
+// RegisterCronJobWithConfig 注册定时任务，ctx 取消或 UnregisterCronJob 后不再执行。
+// 正在执行的任务不随之取消，而是继续执行至多 conf.Grace。返回的 chan 在任务退出后关闭
+func RegisterCronJobWithConfig(ctx context.Context, name string, conf CronJobConfig, job CronJob) <-chan struct{} {
+	ctx, cancel := context.WithCancel(ctx)
+	entry := &cronJobEntry{cancel: cancel}
 	notifyChan := make(chan struct{}, 1)
+	gCronJobLock.Lock()
+	if old, ok := gCronJobs[name]; ok {
+		old.cancel()
+	}
+	gCronJobs[name] = entry
 	Subscribe(getCronJobTopic(name), name, func(ctx context.Context, msgAny any) error {
 		select {
-		case <-ctx.Done():
-		case <-notifyChan:
+		case notifyChan <- struct{}{}:
+		default:
+			// 已有待执行的通知，合并为一次
 		}
 		return nil
 	})
+	gCronJobLock.Unlock()
 
 	ctx = log.AppendCtx(ctx, "cron_job", name)
 	log.InfoC(ctx, "register cron job")
 
+	done := make(chan struct{})
 	go func() {
-		timer := time.NewTimer(interval)
+		defer close(done)
+		first := conf.Interval()
+		if conf.Immediate {
+			first = 0
+		}
+		timer := time.NewTimer(first)
+		defer timer.Stop()
+		failures := 0
 		for {
 			select {
 			case <-ctx.Done():
-				log.InfoC(ctx, "exit cron job")
-				return
 			case <-timer.C:
 				log.DebugC(ctx, "begin exec cron job by timer")
 			case <-notifyChan:
 				log.DebugC(ctx, "begin exec cron job by notify")
 			}
+			// ctx 与定时器同时就绪时也不再执行
+			if ctx.Err() != nil {
+				unregisterCronJob(name, entry)
+				log.InfoC(ctx, "exit cron job")
+				return
+			}
 
-			err := job(ctx)
+			// 每次执行使用新的请求 ID，便于关联同一次执行的日志
+			runCtx := log.WithRequestId(ctx, log.NewRequestId())
+			err := runCronJob(runCtx, conf.Grace, job)
+			if err != nil {
+				failures++
+			} else {
+				failures = 0
+			}
+			next := conf.next(failures)
 			if err != nil {
-				log.ErrorC(ctx, "exec cron job failed", "err", err)
+				log.ErrorC(runCtx, "exec cron job failed", "failures", failures, "next", next, "err", err)
 			}
-			timer.Reset(interval)
+			timer.Reset(next)
 		}
 	}()
+	return done
+}
+
+// runCronJob 执行一次任务，ctx 取消后任务继续执行至多 grace，grace 为 0 时不取消
+func runCronJob(ctx context.Context, grace time.Duration, job CronJob) error {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	if grace > 0 {
+		stop := context.AfterFunc(ctx, func() {
+			log.InfoC(ctx, "wait for running cron job", "grace", grace)
+			time.AfterFunc(grace, cancel)
+		})
+		defer stop()
+	}
+	return job(jobCtx)
+}
+
+// UnregisterCronJob 停止定时任务，不等待正在执行的任务结束
+func UnregisterCronJob(name string) {
+	gCronJobLock.Lock()
+	defer gCronJobLock.Unlock()
+	if entry, ok := gCronJobs[name]; ok {
+		entry.cancel()
+		delete(gCronJobs, name)
+		Unsubscribe(getCronJobTopic(name), name)
+	}
+}
+
+// unregisterCronJob 在任务退出时清理注册，同名任务已被重新注册时不处理
+func unregisterCronJob(name string, entry *cronJobEntry) {
+	gCronJobLock.Lock()
+	defer gCronJobLock.Unlock()
+	if gCronJobs[name] == entry {
+		delete(gCronJobs, name)
+		Unsubscribe(getCronJobTopic(name), name)
+	}
 }
 
 func TriggerCronJob(ctx context.Context, name string) {
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/log/logtest"
+)
+
+func TestCronJob(t *testing.T) {
+	r := logtest.ReplaceDefault(t)
+
+	done := make(chan struct{}, 1)
+	RegisterCronJob("cron_test", time.Hour, func(ctx context.Context) error {
+		done <- struct{}{}
+		return errors.New("job failed")
+	})
+	r.AssertLogged(t, slog.LevelInfo, "register cron job", "cron_job", "cron_test")
+
+	TriggerCronJob(context.Background(), "cron_test")
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("cron job not triggered")
+	}
+	assert.Eventually(t, func() bool {
+		return r.Has(slog.LevelError, "exec cron job failed", "cron_job", "cron_test", "err", "job failed")
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestCronJobBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var times []time.Time
+	done := RegisterCronJobWithConfig(ctx, "cron_backoff_test", CronJobConfig{
+		Interval:  func() time.Duration { return time.Hour },
+		Backoff:   func() time.Duration { return 20 * time.Millisecond },
+		Immediate: true,
+	}, func(ctx context.Context) error {
+		times = append(times, time.Now())
+		if len(times) == 4 {
+			cancel()
+		}
+		return errors.New("job failed")
+	})
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("cron job not exited")
+	}
+	// 失败后的间隔依次为 20ms、40ms、80ms
+	assert.Len(t, times, 4)
+	for i, want := range []time.Duration{20, 40, 80} {
+		assert.GreaterOrEqual(t, times[i+1].Sub(times[i]), want*time.Millisecond)
+	}
+}
+
+func TestCronJobConfigNext(t *testing.T) {
+	c := CronJobConfig{
+		Interval: func() time.Duration { return time.Minute },
+		Backoff:  func() time.Duration { return 10 * time.Second },
+	}
+	assert.Equal(t, time.Minute, c.next(0))
+	assert.Equal(t, 10*time.Second, c.next(1))
+	assert.Equal(t, 40*time.Second, c.next(3))
+	assert.Equal(t, time.Minute, c.next(10))
+
+	c.Backoff = nil
+	assert.Equal(t, time.Minute, c.next(3))
+}
+
+func TestUnregisterCronJob(t *testing.T) {
+	done := RegisterCronJobWithConfig(context.Background(), "cron_unregister_test", CronJobConfig{
+		Interval: func() time.Duration { return time.Hour },
+	}, func(ctx context.Context) error { return nil })
+	UnregisterCronJob("cron_unregister_test")
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("cron job not exited")
+	}
+}
+
+func TestCronJobGraceful(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var jobErr error
+	done := RegisterCronJobWithConfig(ctx, "cron_graceful_test", CronJobConfig{
+		Interval:  func() time.Duration { return time.Hour },
+		Immediate: true,
+	}, func(ctx context.Context) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		jobErr = ctx.Err()
+		return nil
+	})
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("cron job not exited")
+	}
+	// 停止调度不取消正在执行的任务，退出后清理注册
+	assert.Nil(t, jobErr)
+	gCronJobLock.Lock()
+	_, ok := gCronJobs["cron_graceful_test"]
+	gCronJobLock.Unlock()
+	assert.False(t, ok)
+	gTopicsLock.Lock()
+	_, ok = gTopics[getCronJobTopic("cron_graceful_test")]
+	gTopicsLock.Unlock()
+	assert.False(t, ok)
+}
+
+func TestCronJobGraceTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := RegisterCronJobWithConfig(ctx, "cron_grace_timeout_test", CronJobConfig{
+		Interval:  func() time.Duration { return time.Hour },
+		Immediate: true,
+		Grace:     20 * time.Millisecond,
+	}, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("cron job not cancelled after grace")
+	}
+}
+
+func TestCronJobReregister(t *testing.T) {
+	first := RegisterCronJobWithConfig(context.Background(), "cron_reregister_test", CronJobConfig{
+		Interval: func() time.Duration { return time.Hour },
+	}, func(ctx context.Context) error { return nil })
+	triggered := make(chan struct{}, 1)
+	second := RegisterCronJobWithConfig(context.Background(), "cron_reregister_test", CronJobConfig{
+		Interval: func() time.Duration { return time.Hour },
+	}, func(ctx context.Context) error {
+		triggered <- struct{}{}
+		return nil
+	})
+	<-first
+
+	// 被替换的任务退出时不清理新注册的任务
+	TriggerCronJob(context.Background(), "cron_reregister_test")
+	select {
+	case <-triggered:
+	case <-time.After(3 * time.Second):
+		t.Fatal("re-registered cron job not triggered")
+	}
+	UnregisterCronJob("cron_reregister_test")
+	<-second
+}
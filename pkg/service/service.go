@@ -10,7 +10,7 @@ import (
 )
 
 var (
-	ErrBusy           = errors.New("busy")
+	ErrBusy           = errutil.ErrBusy
 	ErrRunningNow     = errors.New("running now")
 	ErrAlreadyStarted = errors.New("already started")
 	ErrNotStartedYet  = errors.New("not started yet")
@@ -110,7 +110,7 @@ func (s *Service) Start(ctx context.Context) error {
 		return ErrBusy
 	}
 	defer s.busyLock.Unlock()
-	ctx = log.AppendCtx(ctx, "tag", "starting")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "starting")
 	return s.start(ctx)
 }
 
@@ -119,7 +119,7 @@ func (s *Service) Stop(ctx context.Context) error {
 		return ErrBusy
 	}
 	defer s.busyLock.Unlock()
-	ctx = log.AppendCtx(ctx, "tag", "stopping")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "stopping")
 	s.stop(ctx)
 	return nil
 }
@@ -129,7 +129,7 @@ func (s *Service) Restart(ctx context.Context) error {
 		return ErrBusy
 	}
 	defer s.busyLock.Unlock()
-	ctx = log.AppendCtx(ctx, "tag", "restarting")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "restarting")
 	return s.restart(ctx)
 }
 
@@ -138,7 +138,7 @@ func (s *Service) Install(ctx context.Context) error {
 		return ErrBusy
 	}
 	defer s.busyLock.Unlock()
-	ctx = log.AppendCtx(ctx, "tag", "installing")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "installing")
 	s.logger.InfoC(ctx, "begin install")
 	return s.instance.Install(ctx)
 }
@@ -149,7 +149,7 @@ func (s *Service) Uninstall(ctx context.Context) error {
 	}
 	defer s.busyLock.Unlock()
 
-	ctx = log.AppendCtx(ctx, "tag", "uninstalling")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "uninstalling")
 	s.logger.InfoC(ctx, "begin uninstall")
 	s.stop(ctx)
 	err := s.instance.Uninstall(ctx)
@@ -165,7 +165,7 @@ func (s *Service) Update(ctx context.Context) error {
 	}
 	defer s.busyLock.Unlock()
 
-	ctx = log.AppendCtx(ctx, "tag", "upgrading")
+	ctx = log.AppendCtx(log.EnsureRequestId(ctx), "tag", "upgrading")
 	isRunning := s.Running()
 	if isRunning {
 		s.stop(ctx)
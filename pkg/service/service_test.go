@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vksir/vkiss-lib/pkg/log"
+	"github.com/vksir/vkiss-lib/pkg/log/logtest"
+)
+
+type fakeInstance struct{}
+
+func (f *fakeInstance) PrepareProcess(ctx context.Context, processCtx context.Context) (map[string]*SubProcess, error) {
+	return map[string]*SubProcess{}, nil
+}
+
+func (f *fakeInstance) WaitActive(waitActiveCtx context.Context) bool {
+	<-waitActiveCtx.Done()
+	return false
+}
+
+func (f *fakeInstance) GracefulShutdown(ctx context.Context, process map[string]*SubProcess) time.Duration {
+	return time.Second
+}
+
+func (f *fakeInstance) Install(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeInstance) Uninstall(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeInstance) Update(ctx context.Context) error {
+	return nil
+}
+
+func TestService(t *testing.T) {
+	r := logtest.New()
+	s := New(&fakeInstance{}, r.Logger())
+
+	ctx := log.WithRequestId(context.Background(), "id1")
+	assert.Nil(t, s.Start(ctx))
+	r.AssertLogged(t, slog.LevelInfo, "begin start", "tag", "starting", log.RequestIdKey, "id1")
+	assert.ErrorIs(t, s.Start(ctx), ErrAlreadyStarted)
+
+	assert.Nil(t, s.Stop(context.Background()))
+	assert.False(t, s.Running())
+	entries := r.Find(slog.LevelInfo, "begin stop", "tag", "stopping")
+	assert.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0].Attrs[log.RequestIdKey])
+}
@@ -54,7 +54,7 @@ func (p *SubProcess) SetTimeout(d time.Duration) *SubProcess {
 }
 
 func (p *SubProcess) SetLogger(l *log.Logger) *SubProcess {
-	p.log = l.With("subprocess", p.name)
+	p.log = l.Named(p.name).With("subprocess", p.name)
 	return p
 }
 
@@ -1,7 +1,33 @@
 package apiutil
 
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vksir/vkiss-lib/pkg/log"
+)
+
 type Response struct {
 	Message string `json:"message"`
 	Data    any    `json:"data"`
 	Code    int    `json:"code"`
+	// Fields 为字段级的校验错误
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// OK 返回 200 与数据
+func OK(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, Response{Data: data, Code: CodeOK})
+}
+
+// Fail 按错误注册表将 err 转换为 HTTP 状态码与错误码并中止请求。
+// 5xx 错误会记录日志，返回给客户端的消息不包含内部细节
+func Fail(c *gin.Context, err error) {
+	e := ToError(err)
+	if e.Status >= http.StatusInternalServerError {
+		log.ErrorC(c.Request.Context(), "request failed",
+			"path", c.Request.URL.Path, "code", e.Code, "err", err)
+	}
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(e.Status, Response{Message: e.Message, Code: e.Code, Fields: e.Fields})
 }
@@ -0,0 +1,102 @@
+package apiutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/validutil"
+)
+
+func serve(t *testing.T, h gin.HandlerFunc, body string) (int, Response) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.POST("/", h)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	var resp Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestOK(t *testing.T) {
+	code, resp := serve(t, func(c *gin.Context) { OK(c, "hi") }, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, CodeOK, resp.Code)
+	assert.Equal(t, "hi", resp.Data)
+}
+
+func TestFail(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   int
+		msg    string
+	}{
+		{errutil.WrapNotFound("user"), http.StatusNotFound, CodeNotFound, "not found"},
+		{errutil.Wrap(errutil.ErrBusy), http.StatusConflict, CodeBusy, "busy"},
+		{ErrBadRequest.Wrap(errors.New("bad level")), http.StatusBadRequest, CodeBadRequest, "bad level"},
+		{errors.New("db password leaked"), http.StatusInternalServerError, CodeInternal, "Internal Server Error"},
+		{&validutil.ValidationError{Fields: []*validutil.FieldError{{Key: "ddns.interval", Tag: "min", Param: "1m"}}},
+			http.StatusBadRequest, CodeValidation, "invalid config"},
+	} {
+		code, resp := serve(t, func(c *gin.Context) { Fail(c, tc.err) }, "")
+		assert.Equal(t, tc.status, code, tc.err.Error())
+		assert.Equal(t, tc.code, resp.Code, tc.err.Error())
+		assert.Equal(t, tc.msg, resp.Message, tc.err.Error())
+	}
+}
+
+func TestRegisterError(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+	RegisterError(errQuota, http.StatusTooManyRequests, 2001)
+	e := ToError(errutil.Wrap(errQuota))
+	assert.Equal(t, http.StatusTooManyRequests, e.Status)
+	assert.Equal(t, 2001, e.Code)
+	assert.ErrorIs(t, e, errQuota)
+}
+
+type bindRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Level string `json:"level" binding:"omitempty,oneof=debug info"`
+	Inner struct {
+		Port int `json:"port" binding:"min=1"`
+	} `json:"inner"`
+}
+
+func TestBind(t *testing.T) {
+	h := func(c *gin.Context) {
+		var req bindRequest
+		if err := Bind(c, &req); err != nil {
+			Fail(c, err)
+			return
+		}
+		OK(c, req.Name)
+	}
+
+	code, resp := serve(t, h, `{"name":"a","inner":{"port":1}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "a", resp.Data)
+
+	code, resp = serve(t, h, `{"level":"warn","inner":{"port":0}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, CodeValidation, resp.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "level", Message: "must be one of [debug, info]"},
+		{Field: "inner.port", Message: "must be at least 1"},
+	}, resp.Fields)
+
+	code, resp = serve(t, h, `{"name":`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, CodeBadRequest, resp.Code)
+}
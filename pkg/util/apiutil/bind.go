@@ -0,0 +1,75 @@
+package apiutil
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/vksir/vkiss-lib/pkg/util/validutil"
+)
+
+var registerTagName sync.Once
+
+// Bind 按 Content-Type 绑定请求并校验 binding tag，失败时返回 *Error，
+// 校验错误按字段给出描述，字段名使用 json、form 或 uri tag
+func Bind(c *gin.Context, obj any) error {
+	registerTagName.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if ok {
+			v.RegisterTagNameFunc(fieldName)
+		}
+	})
+	err := c.ShouldBind(obj)
+	if err == nil {
+		return nil
+	}
+	return bindError(err)
+}
+
+// BindUri 绑定路径参数，见 Bind
+func BindUri(c *gin.Context, obj any) error {
+	err := c.ShouldBindUri(obj)
+	if err == nil {
+		return nil
+	}
+	return bindError(err)
+}
+
+func bindError(err error) error {
+	e := ToError(err)
+	if e.Code == CodeValidation {
+		return e
+	}
+	// 其余均为请求格式错误，如 JSON 语法错误
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: err.Error(), err: err}
+}
+
+func validationError(errs validator.ValidationErrors) *Error {
+	fields := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		// 去掉 Namespace 中的结构体类型名
+		_, name, ok := strings.Cut(e.Namespace(), ".")
+		if !ok {
+			name = e.Field()
+		}
+		fields = append(fields, FieldError{Field: name, Message: validutil.Describe(e.Tag(), e.Param())})
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: "invalid request", Fields: fields, err: errs}
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,129 @@
+package apiutil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/util/validutil"
+)
+
+// 错误码，通用错误与 HTTP 状态码一致，业务错误从 1000 开始
+const (
+	CodeOK              = 0
+	CodeBadRequest      = http.StatusBadRequest
+	CodeUnauthorized    = http.StatusUnauthorized
+	CodeForbidden       = http.StatusForbidden
+	CodeNotFound        = http.StatusNotFound
+	CodeConflict        = http.StatusConflict
+	CodeTooManyRequests = http.StatusTooManyRequests
+	CodeInternal        = http.StatusInternalServerError
+	CodeTimeout         = http.StatusGatewayTimeout
+
+	CodeValidation = 1001
+	CodeBusy       = 1002
+	CodeErrType    = 1003
+)
+
+// 常用错误，可通过 Wrap 附加原因，Message 为空时使用原因的描述
+var (
+	ErrBadRequest = NewError(http.StatusBadRequest, CodeBadRequest, "")
+	ErrNotFound   = NewError(http.StatusNotFound, CodeNotFound, "")
+)
+
+// Error 为可直接返回给客户端的错误
+type Error struct {
+	Status  int
+	Code    int
+	Message string
+	Fields  []FieldError
+	err     error
+}
+
+func NewError(status, code int, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap 返回携带 err 的副本，Message 为空时使用 err 的描述
+func (e *Error) Wrap(err error) *Error {
+	ne := *e
+	ne.err = err
+	if ne.Message == "" && err != nil {
+		ne.Message = err.Error()
+	}
+	return &ne
+}
+
+func (e *Error) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// FieldError 为单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type registered struct {
+	target error
+	status int
+	code   int
+}
+
+var (
+	gRegistryLock sync.RWMutex
+	gRegistry     []registered
+)
+
+func init() {
+	RegisterError(errutil.ErrNotFound, http.StatusNotFound, CodeNotFound)
+	RegisterError(errutil.ErrBusy, http.StatusConflict, CodeBusy)
+	RegisterError(errutil.ErrErrType, http.StatusBadRequest, CodeErrType)
+}
+
+// RegisterError 注册哨兵错误对应的 HTTP 状态码与错误码，通过 errors.Is 匹配，先注册的优先
+func RegisterError(target error, status, code int) {
+	gRegistryLock.Lock()
+	defer gRegistryLock.Unlock()
+	gRegistry = append(gRegistry, registered{target: target, status: status, code: code})
+}
+
+// ToError 将任意错误转换为 *Error，未注册的错误视为内部错误
+func ToError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var cfgErr *validutil.ValidationError
+	if errors.As(err, &cfgErr) {
+		fields := make([]FieldError, 0, len(cfgErr.Fields))
+		for _, f := range cfgErr.Fields {
+			fields = append(fields, FieldError{Field: f.Key, Message: validutil.Describe(f.Tag, f.Param)})
+		}
+		return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: "invalid config", Fields: fields, err: err}
+	}
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		return validationError(errs)
+	}
+
+	gRegistryLock.RLock()
+	defer gRegistryLock.RUnlock()
+	for _, r := range gRegistry {
+		if errors.Is(err, r.target) {
+			return &Error{Status: r.status, Code: r.code, Message: r.target.Error(), err: err}
+		}
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal,
+		Message: http.StatusText(http.StatusInternalServerError), err: err}
+}
@@ -0,0 +1,209 @@
+// Package netutil 提供网络相关的工具，如 PROXY protocol 监听器
+package netutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+)
+
+// proxyV2Sig 为 PROXY protocol v2 的 12 字节签名
+var proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyV1MaxLen 为 v1 头部的最大长度，含 CRLF
+	proxyV1MaxLen = 107
+	// defaultHeaderTimeout 为读取 PROXY 头部的超时
+	defaultHeaderTimeout = 5 * time.Second
+)
+
+// ParsePrefixes 解析 CIDR 或单个 IP 的列表
+func ParsePrefixes(ss []string) ([]netip.Prefix, error) {
+	var ps []netip.Prefix
+	for _, s := range ss {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			ps = append(ps, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, errutil.WrapF("invalid cidr or ip %q", s)
+		}
+		ps = append(ps, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return ps, nil
+}
+
+// ContainsAddr 判断 addr 是否属于 ps 中任一网段
+func ContainsAddr(ps []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range ps {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyListener 解析来自可信代理的连接上的 PROXY protocol v1/v2 头部，
+// 将连接的 RemoteAddr 替换为头部中的客户端地址。
+// 头部可选，不带头部的连接保持原样；来自不可信地址的连接不解析头部
+type ProxyListener struct {
+	net.Listener
+	// Trusted 为允许发送 PROXY 头部的地址
+	Trusted []netip.Prefix
+	// HeaderTimeout 为读取头部的超时，默认 5s
+	HeaderTimeout time.Duration
+}
+
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	ap, err := netip.ParseAddrPort(c.RemoteAddr().String())
+	if err != nil || !ContainsAddr(l.Trusted, ap.Addr()) {
+		return c, nil
+	}
+	timeout := l.HeaderTimeout
+	if timeout == 0 {
+		timeout = defaultHeaderTimeout
+	}
+	return &proxyConn{Conn: c, r: bufio.NewReaderSize(c, 256), timeout: timeout}, nil
+}
+
+// proxyConn 在首次 Read 或 RemoteAddr 时读取头部，避免阻塞 Accept
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.remote, c.err = readProxyHeader(c.r)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			_ = c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader 读取 PROXY 头部，没有头部或为 LOCAL/UNKNOWN 时返回 nil
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(proxyV2Sig))
+	switch {
+	case bytes.Equal(b, proxyV2Sig):
+		return readProxyV2(r)
+	case isPrefix(b, "PROXY "):
+		return readProxyV1(r)
+	case err != nil && len(b) == 0:
+		return nil, errutil.WrapF("read proxy header failed: %w", err)
+	default:
+		return nil, nil
+	}
+}
+
+func isPrefix(b []byte, s string) bool {
+	return len(b) >= len(s) && string(b[:len(s)]) == s
+}
+
+// readProxyV1 解析形如 "PROXY TCP4 1.2.3.4 5.6.7.8 1111 2222\r\n" 的头部
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, errutil.WrapF("read proxy v1 header failed: %w", err)
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errutil.WrapF("invalid proxy v1 header")
+	}
+	fields := strings.Fields(s)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errutil.WrapF("invalid proxy v1 header %q", s)
+	}
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, errutil.WrapF("invalid proxy v1 source address: %w", err)
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, errutil.WrapF("invalid proxy v1 source port: %w", err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readProxyV2 解析二进制头部，仅处理 TCP over IPv4/IPv6，忽略 TLV
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, errutil.WrapF("read proxy v2 header failed: %w", err)
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, errutil.WrapF("unsupported proxy v2 version %d", hdr[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errutil.WrapF("read proxy v2 addresses failed: %w", err)
+	}
+	// LOCAL 命令为代理自身的连接，如健康检查
+	if hdr[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch hdr[13] {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, errutil.WrapF("short proxy v2 ipv4 addresses")
+		}
+		addr := netip.AddrFrom4([4]byte(body[0:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(body[8:10]))), nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, errutil.WrapF("short proxy v2 ipv6 addresses")
+		}
+		addr := netip.AddrFrom16([16]byte(body[0:16]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(body[32:34]))), nil
+	default:
+		return nil, nil
+	}
+}
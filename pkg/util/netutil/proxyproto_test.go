@@ -0,0 +1,108 @@
+package netutil
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proxyEcho 启动 ProxyListener，对每个连接回写其 RemoteAddr 与读到的第一段数据
+func proxyEcho(t *testing.T, trusted ...string) string {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	ps, err := ParsePrefixes(trusted)
+	require.NoError(t, err)
+	pl := &ProxyListener{Listener: l, Trusted: ps}
+	t.Cleanup(func() { _ = pl.Close() })
+	go func() {
+		for {
+			c, err := pl.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				buf := make([]byte, 64)
+				n, err := c.Read(buf)
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(c, c.RemoteAddr().String()+" "+string(buf[:n]))
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func roundTrip(t *testing.T, addr string, data []byte) string {
+	c, err := net.Dial("tcp4", addr)
+	require.NoError(t, err)
+	defer c.Close()
+	_, err = c.Write(data)
+	require.NoError(t, err)
+	b, _ := io.ReadAll(c)
+	return string(b)
+}
+
+func proxyV2(src netip.AddrPort, dst netip.AddrPort) []byte {
+	b := append([]byte{}, proxyV2Sig...)
+	var addrs []byte
+	fam := byte(0x11)
+	if src.Addr().Is6() {
+		fam = 0x21
+	}
+	addrs = append(addrs, src.Addr().AsSlice()...)
+	addrs = append(addrs, dst.Addr().AsSlice()...)
+	addrs = binary.BigEndian.AppendUint16(addrs, src.Port())
+	addrs = binary.BigEndian.AppendUint16(addrs, dst.Port())
+	b = append(b, 0x21, fam)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(addrs)))
+	return append(b, addrs...)
+}
+
+func TestProxyListener(t *testing.T) {
+	addr := proxyEcho(t, "127.0.0.0/8")
+
+	assert.Equal(t, "1.2.3.4:5678 hello",
+		roundTrip(t, addr, []byte("PROXY TCP4 1.2.3.4 10.0.0.1 5678 80\r\nhello")))
+	assert.Equal(t, "[2001:db8::1]:443 hello",
+		roundTrip(t, addr, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 443 80\r\nhello")))
+
+	v2 := proxyV2(netip.MustParseAddrPort("5.6.7.8:1000"), netip.MustParseAddrPort("10.0.0.1:80"))
+	assert.Equal(t, "5.6.7.8:1000 hello", roundTrip(t, addr, append(v2, "hello"...)))
+	v2 = proxyV2(netip.MustParseAddrPort("[2001:db8::9]:1000"), netip.MustParseAddrPort("[2001:db8::2]:80"))
+	assert.Equal(t, "[2001:db8::9]:1000 hello", roundTrip(t, addr, append(v2, "hello"...)))
+
+	// 不带头部与 UNKNOWN 时保持原地址
+	res := roundTrip(t, addr, []byte("GET / HTTP/1.1\r\n"))
+	assert.Regexp(t, `^127\.0\.0\.1:\d+ GET / HTTP/1.1`, res)
+	res = roundTrip(t, addr, []byte("PROXY UNKNOWN\r\nhello"))
+	assert.Regexp(t, `^127\.0\.0\.1:\d+ hello$`, res)
+
+	// 非法头部断开连接
+	assert.Equal(t, "", roundTrip(t, addr, []byte("PROXY TCP4 x y z w\r\n")))
+}
+
+func TestProxyListenerUntrusted(t *testing.T) {
+	addr := proxyEcho(t, "10.0.0.0/8")
+	res := roundTrip(t, addr, []byte("PROXY TCP4 1.2.3.4 10.0.0.1 5678 80\r\n"))
+	assert.Regexp(t, `^127\.0\.0\.1:\d+ PROXY TCP4`, res)
+}
+
+func TestParsePrefixes(t *testing.T) {
+	ps, err := ParsePrefixes([]string{"10.1.2.3/8", "::ffff:192.168.1.1", "2001:db8::/32"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", ps[0].String())
+	assert.Equal(t, "192.168.1.1/32", ps[1].String())
+	assert.True(t, ContainsAddr(ps, netip.MustParseAddr("::ffff:10.9.9.9")))
+	assert.True(t, ContainsAddr(ps, netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, ContainsAddr(ps, netip.MustParseAddr("192.168.1.2")))
+
+	_, err = ParsePrefixes([]string{"x"})
+	assert.Error(t, err)
+}
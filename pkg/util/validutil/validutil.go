@@ -0,0 +1,78 @@
+// Package validutil 为校验错误的公共定义，不依赖配置与日志，供 cfg 与 apiutil 共用
+package validutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError 描述单个配置项的校验失败
+type FieldError struct {
+	Key   string
+	Flag  string
+	Tag   string
+	Param string
+	Value any
+}
+
+func (e *FieldError) Error() string {
+	key := e.Key
+	if e.Flag != "" {
+		key = fmt.Sprintf("%s (--%s)", e.Key, e.Flag)
+	}
+	return fmt.Sprintf("%s %s", key, Describe(e.Tag, e.Param))
+}
+
+// ValidationError 汇总所有校验失败的配置项
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{"invalid config:"}
+	for _, f := range e.Fields {
+		lines = append(lines, "  "+f.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Describe 将 validator 的 tag 转换为可读描述
+func Describe(tag, param string) string {
+	switch tag {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", param)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", param)
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", param)
+	case "lt":
+		return fmt.Sprintf("must be less than %s", param)
+	case "len":
+		return fmt.Sprintf("must have length %s", param)
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", strings.Join(strings.Fields(param), ", "))
+	case "hostname", "hostname_rfc1123", "fqdn":
+		return "must be a valid hostname"
+	case "hostname_port":
+		return "must be a valid host:port"
+	case "ip", "ipv4", "ipv6":
+		return fmt.Sprintf("must be a valid %s address", tag)
+	case "cidr", "cidrv4", "cidrv6":
+		return "must be a valid CIDR"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "secret":
+		return fmt.Sprintf("cannot be resolved: %s", param)
+	case "parse":
+		return fmt.Sprintf("cannot be parsed: %s", param)
+	case "load":
+		return fmt.Sprintf("is invalid: %s", param)
+	default:
+		if param != "" {
+			return fmt.Sprintf("failed on %s=%s", tag, param)
+		}
+		return fmt.Sprintf("failed on %s", tag)
+	}
+}
@@ -1,14 +1,13 @@
 package steam
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/go-resty/resty/v2"
-	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"github.com/vksir/vkiss-lib/pkg/httpclient"
 )
 
 type CmdAppInfoResponse struct {
@@ -357,24 +356,11 @@ func GetNewsForApp(appId string) (GetNewsForAppResponse, error) {
 	return res, err
 }
 
+var client = httpclient.New(httpclient.Config{Timeout: 5 * time.Second})
+
 func request(method, url string, data map[string]string, a any) error {
-	client := resty.New().SetTimeout(5 * time.Second).R()
 	if method == http.MethodGet {
-		client.SetQueryParams(data)
-	} else {
-		client.SetHeader("Content-Type", "application/x-www-form-urlencoded").
-			SetFormData(data)
-	}
-	resp, err := client.Execute(method, url)
-	if err != nil {
-		return errutil.Wrap(err)
-	}
-	if resp.StatusCode() != http.StatusOK {
-		return errutil.WrapF("request failed: code=%d, body=%s", resp.StatusCode(), resp.Body())
-	}
-	err = json.Unmarshal(resp.Body(), a)
-	if err != nil {
-		return errutil.Wrap(err)
+		return client.Get(context.Background(), url, data, a)
 	}
-	return nil
+	return client.PostForm(context.Background(), url, data, a)
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/vksir/vkiss-lib/pkg/log"
 	"github.com/vksir/vkiss-lib/pkg/service"
 	"github.com/vksir/vkiss-lib/pkg/util/errutil"
+	"log/slog"
 	"os/exec"
 	"path/filepath"
 )
@@ -22,7 +23,7 @@ type Steamcmd struct {
 }
 
 func NewSteamcmd(path string, log *log.Logger) *Steamcmd {
-	return &Steamcmd{executePath: path, logger: log.With("tag", "steamcmd")}
+	return &Steamcmd{executePath: path, logger: log.Named("steamcmd")}
 }
 
 func (s *Steamcmd) SetForceInstallDir(dir string) *Steamcmd {
@@ -73,7 +74,10 @@ func (s *Steamcmd) execute(ctx context.Context, extArgs []string) error {
 		SetLogger(s.logger).
 		SetDir(filepath.Dir(exe))
 
-	logger := s.logger.With("output", "steamcmd")
+	// steamcmd 每行输出各不相同，整体按一个 key 限流
+	sample := log.DefaultSampleConfig
+	sample.Key = func(r slog.Record) string { return "steamcmd" }
+	logger := s.logger.With("output", "steamcmd").Sampled(sample)
 	process.RegisterOutputFunc("log", func(line []byte) {
 		logger.Debug(string(line))
 	})